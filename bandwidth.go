@@ -0,0 +1,212 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Bandwidth limiting of package downloads, using each manager's own throttle
+
+package main
+
+// Import packages
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// // Bandwidth setting in bytes per second (0 is unlimited), set by flags
+var maxBandwidth int64
+
+// // Temporary Pacman and Zypp configurations, throttled
+var pacmanThrottledConfig string
+var zyppThrottledConfig string
+
+// // Restores the rate limit kept by Snap, once updates finish
+var snapRateLimitRestore func()
+
+// Method to parse a bandwidth such as "512K", "2M" or "1G" (bytes per second)
+func ParseBandwidth(value string) (int64, error) {
+	limit, err := ParseSize(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(value)), "/S"))
+	if err != nil {
+		return 0, errors.New("invalid bandwidth [" + value + "], expected e.g. 512K, 2M")
+	}
+	return limit, nil
+}
+
+// Method to write a copy of a configuration file, replacing a setting of one of its sections
+func WriteConfigWithSetting(original string, pattern string, section string, key string, setting string) (string, error) {
+	// Initialise variables
+	var lines []string
+	var added bool
+
+	content, err := os.ReadFile(original)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		name, _, _ := strings.Cut(line, "=")
+		if strings.TrimSpace(name) == key {
+			continue
+		}
+		lines = append(lines, line)
+		if strings.TrimSpace(line) == section {
+			lines = append(lines, setting)
+			added = true
+		}
+	}
+	// Add the section, if missing
+	if !added {
+		lines = append(lines, section, setting)
+	}
+	file, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err = file.WriteString(strings.Join(lines, "\n")); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return filepath.Clean(file.Name()), nil
+}
+
+// Method to write a copy of pacman.conf using curl with a rate limit as XferCommand
+func WritePacmanThrottledConfig(limit int64) (string, error) {
+	// Re-use the configuration written earlier in this run
+	if pacmanThrottledConfig != "" {
+		return pacmanThrottledConfig, nil
+	}
+	xferCommand := fmt.Sprintf("XferCommand = /usr/bin/curl --limit-rate %d -L -C - -f -o %%o %%u", limit)
+	path, err := WriteConfigWithSetting("/etc/pacman.conf", "update_full-pacman-*.conf", "[options]", "XferCommand", xferCommand)
+	if err != nil {
+		return "", err
+	}
+	pacmanThrottledConfig = path
+	return pacmanThrottledConfig, nil
+}
+
+// Method to write a copy of zypp.conf with a maximum download speed, given to libzypp through ZYPP_CONF
+func WriteZyppThrottledConfig(limit int64) (string, error) {
+	// Re-use the configuration written earlier in this run
+	if zyppThrottledConfig != "" {
+		return zyppThrottledConfig, nil
+	}
+	// Download speeds of libzypp are in KB/s
+	setting := fmt.Sprintf("download.max_download_speed = %d", max(limit/1024, 1))
+	path, err := WriteConfigWithSetting("/etc/zypp/zypp.conf", "update_full-zypp-*.conf", "[main]", "download.max_download_speed", setting)
+	if err != nil {
+		return "", err
+	}
+	zyppThrottledConfig = path
+	return zyppThrottledConfig, nil
+}
+
+// Method to apply the rate limit kept by snapd, restoring the previous limit in BandwidthCleanup
+func ApplySnapRateLimit(manager ScopedManager, limit int64) error {
+	// Initialise variables
+	var restore []string
+
+	// An unset limit makes snap get fail, and is restored by unsetting it again
+	name, args, options := manager.Command([]string{"get", "system", "refresh.rate-limit"}, nil, false)
+	stdout, _, err := RunCommand(options, name, args...)
+	switch previous := strings.TrimSpace(string(stdout)); {
+	case err != nil || previous == "":
+		restore = []string{"unset", "system", "refresh.rate-limit"}
+	default:
+		restore = []string{"set", "system", "refresh.rate-limit=" + previous}
+	}
+
+	name, args, options = manager.Command([]string{"set", "system", fmt.Sprintf("refresh.rate-limit=%dB", limit)}, nil, false)
+	if _, stderr, err := RunCommand(options, name, args...); err != nil {
+		return errors.New(strings.TrimSpace(err.Error() + " " + string(stderr)))
+	}
+	logger.Info("snap rate limit set", "limit", limit, "restore", restore)
+	snapRateLimitRestore = func() {
+		name, args, options := manager.Command(restore, nil, false)
+		if _, stderr, err := RunCommand(options, name, args...); err != nil {
+			PrintWarning("!!Could NOT restore the Snap rate limit (snap " + strings.Join(restore, " ") + "): " + strings.TrimSpace(string(stderr)))
+			logger.Warn("snap rate limit not restored", "err", err)
+		}
+	}
+	return nil
+}
+
+// Method to apply bandwidth limits kept by the package manager itself, such as Snap's
+//
+// Returns false if the limit could not be applied.
+func ApplyManagerBandwidth(manager ScopedManager, pkgNum int, official bool) bool {
+	switch {
+	// Snap package manager
+	case maxBandwidth > 0 && !official && pkgNum == 1:
+		if err := ApplySnapRateLimit(manager, maxBandwidth); err != nil {
+			PrintWarning("!!Could NOT set the Snap rate limit:", err)
+			logger.Warn("snap rate limit not set", "err", err)
+			return false
+		}
+	}
+	return true
+}
+
+// Method to remove temporary files written for bandwidth limiting, and restore limits kept by package managers
+func BandwidthCleanup() {
+	for _, path := range []*string{&pacmanThrottledConfig, &zyppThrottledConfig} {
+		if *path != "" {
+			os.Remove(*path)
+			*path = ""
+		}
+	}
+	if snapRateLimitRestore != nil {
+		snapRateLimitRestore()
+		snapRateLimitRestore = nil
+	}
+}
+
+// Method to find the options limiting the download bandwidth of a package manager
+//
+// Returns options placed after the step's actions, environment variables, and
+// whether the package manager can be throttled at all.
+func BandwidthArgs(pkgNum int, official bool) ([]string, []string, bool) {
+	// Nothing to do without a limit
+	if maxBandwidth <= 0 {
+		return nil, nil, true
+	}
+
+	switch official {
+	// Official package managers
+	case true:
+		switch pkgNum {
+		// Apt package manager (limit in KB/s)
+		case 0:
+			return []string{"-o", fmt.Sprintf("Acquire::http::Dl-Limit=%d", max(maxBandwidth/1024, 1))}, nil, true
+		// Dnf & Yum package manager (limit in bytes/s)
+		case 1, 4:
+			return []string{fmt.Sprintf("--setopt=throttle=%d", maxBandwidth)}, nil, true
+		// Zypper package manager, through a copy of zypp.conf
+		case 3:
+			path, err := WriteZyppThrottledConfig(maxBandwidth)
+			if err != nil {
+				PrintWarning("!!Could not write throttled Zypp configuration:", err)
+				return nil, nil, false
+			}
+			return nil, []string{"ZYPP_CONF=" + path}, true
+		// Arch Linux
+		case 8:
+			path, err := WritePacmanThrottledConfig(maxBandwidth)
+			if err != nil {
+				PrintWarning("!!Could not write throttled Pacman configuration:", err)
+				return nil, nil, false
+			}
+			return []string{"--config", path}, nil, true
+		}
+	// Alternative package managers
+	case false:
+		switch pkgNum {
+		// Snap package manager, throttled through snapd by ApplyManagerBandwidth
+		case 1:
+			return nil, nil, true
+		}
+	}
+
+	// Every other package manager (e.g. Flatpak) has no per-run throttle
+	return nil, nil, false
+}
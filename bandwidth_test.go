@@ -0,0 +1,109 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Tests of bandwidth limiting, with a fake snap binary
+
+package main
+
+// Import packages
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBandwidth(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{"512K", 512 * 1024, false},
+		{"2M/s", 2 * 1024 * 1024, false},
+		{"1.5MiB/s", 1536 * 1024, false},
+		{"0", 0, false},
+		{"fast", 0, true},
+	}
+	for _, test := range tests {
+		got, err := ParseBandwidth(test.value)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("ParseBandwidth(%q) = %d, %v, want %d (error %v)", test.value, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestWriteConfigWithSetting(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		want     string
+	}{
+		{"replaces setting in section", "[main]\nfoo = 1\ndownload.max_download_speed = 0\n[other]\n", "[main]\nspeed\nfoo = 1\n[other]\n"},
+		{"adds missing section", "# comment\n", "# comment\n\n[main]\nspeed"},
+		{"missing file", "", "\n[main]\nspeed"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Initialise variables
+			original := filepath.Join(t.TempDir(), "zypp.conf")
+			if test.original != "" {
+				if err := os.WriteFile(original, []byte(test.original), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			path, err := WriteConfigWithSetting(original, "update_full-test-*.conf", "[main]", "download.max_download_speed", "speed")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(path)
+			content, _ := os.ReadFile(path)
+			if string(content) != test.want {
+				t.Errorf("content = %q, want %q", content, test.want)
+			}
+		})
+	}
+}
+
+func TestApplySnapRateLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		previous    string
+		wantCommand []string
+	}{
+		{"no previous limit", "", []string{"get system refresh.rate-limit", "set system refresh.rate-limit=1048576B", "unset system refresh.rate-limit"}},
+		{"previous limit", "512kB", []string{"get system refresh.rate-limit", "set system refresh.rate-limit=1048576B", "set system refresh.rate-limit=512kB"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Initialise variables
+			dir := t.TempDir()
+			calls := filepath.Join(dir, "calls")
+			// Fake snap, recording its arguments and failing "get" without a previous limit
+			script := "#!/bin/sh\necho \"$*\" >> " + calls + "\n"
+			switch test.previous {
+			case "":
+				script += "[ \"$1\" = get ] && exit 1\n"
+			default:
+				script += "[ \"$1\" = get ] && echo " + test.previous + "\n"
+			}
+			snap := filepath.Join(dir, "snap")
+			if err := os.WriteFile(snap, []byte(script+"exit 0\n"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			maxBandwidth = 1024 * 1024
+			defer func() { maxBandwidth = 0 }()
+
+			if !ApplyManagerBandwidth(ScopedManager{Path: snap}, 1, false) {
+				t.Fatal("rate limit not applied")
+			}
+			BandwidthCleanup()
+			content, _ := os.ReadFile(calls)
+			if got := strings.Split(strings.TrimSpace(string(content)), "\n"); !reflect.DeepEqual(got, test.wantCommand) {
+				t.Errorf("snap calls = %q, want %q", got, test.wantCommand)
+			}
+		})
+	}
+}
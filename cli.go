@@ -0,0 +1,361 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Command line: subcommands, and flags with long names (--name) and short aliases (-n)
+
+package main
+
+// Import packages
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// // Groups of flags, in the order help prints them
+const FLAG_GROUP_INFO string = "Informational (overrides all functional flags)"
+const FLAG_GROUP_UPDATE string = "Updating"
+const FLAG_GROUP_NETWORK string = "Network"
+const FLAG_GROUP_PRIVILEGE string = "Privileges"
+const FLAG_GROUP_POLICY string = "Policy"
+const FLAG_GROUP_OUTPUT string = "Output and logging"
+const FLAG_GROUP_CONFIG string = "Configuration"
+const FLAG_GROUP_COMMAND string = "Command-specific"
+
+var FLAG_GROUPS []string = []string{FLAG_GROUP_INFO, FLAG_GROUP_UPDATE, FLAG_GROUP_NETWORK, FLAG_GROUP_PRIVILEGE, FLAG_GROUP_POLICY, FLAG_GROUP_OUTPUT, FLAG_GROUP_CONFIG, FLAG_GROUP_COMMAND}
+
+// // Command run when none is given
+const DEFAULT_COMMAND string = "update"
+
+// A subcommand, e.g. update_full check
+type CommandDef struct {
+	Name  string
+	Args  string
+	Usage string
+}
+
+// // Every subcommand, in the order help prints them
+var COMMANDS []CommandDef = []CommandDef{
+	{Name: "update", Usage: "Update every detected package manager (default)"},
+	{Name: "check", Usage: "Test connectivity and repositories, and list pending updates (exit 100 if any)"},
+	{Name: "list-managers", Usage: "List supported package managers, and which are detected"},
+	{Name: "history", Usage: "Show reports of earlier runs"},
+	{Name: "doctor", Usage: "Check the health of package managers and the system, without changing anything"},
+	{Name: "config", Args: "validate | show", Usage: "Check the configuration, or print it with --effective"},
+	{Name: "completion", Args: "bash | zsh | fish", Usage: "Print a shell completion script"},
+	{Name: "version", Usage: "Print version"},
+	{Name: "help", Usage: "Print this help message"},
+}
+
+// A flag, settable as --name or through any of its aliases (-alias)
+type FlagDef struct {
+	Name     string
+	Aliases  []string
+	ArgName  string
+	Usage    string
+	Value    flag.Value
+	Group    string
+	Commands []string
+	NoConfig bool
+	Choices  func(config *Config) []string
+	Default  string
+	Set      bool
+}
+
+// // Every flag, in the order help prints them
+var FLAG_REGISTRY []*FlagDef
+
+// // Flag values pointing at the settings they change
+type BoolValue struct{ Target *bool }
+type StringValue struct{ Target *string }
+type IntValue struct{ Target *int }
+type DurationValue struct{ Target *time.Duration }
+
+// Methods of boolean flags
+func (value BoolValue) String() string   { return strconv.FormatBool(*value.Target) }
+func (value BoolValue) IsBoolFlag() bool { return true }
+func (value BoolValue) Set(text string) error {
+	parsed, err := strconv.ParseBool(text)
+	if err != nil {
+		return errors.New("expected true or false")
+	}
+	*value.Target = parsed
+	return nil
+}
+
+// Methods of string flags
+func (value StringValue) String() string { return *value.Target }
+func (value StringValue) Set(text string) error {
+	*value.Target = text
+	return nil
+}
+
+// Methods of integer flags
+func (value IntValue) String() string { return strconv.Itoa(*value.Target) }
+func (value IntValue) Set(text string) error {
+	parsed, err := strconv.Atoi(text)
+	if err != nil {
+		return errors.New("expected a whole number")
+	}
+	*value.Target = parsed
+	return nil
+}
+
+// Methods of duration flags
+func (value DurationValue) String() string { return value.Target.String() }
+func (value DurationValue) Set(text string) error {
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return errors.New("expected a duration, e.g. 30s or 5m")
+	}
+	*value.Target = parsed
+	return nil
+}
+
+// Method to add a flag to the registry, remembering its default
+func RegisterFlag(def FlagDef) *FlagDef {
+	def.Default = def.Value.String()
+	FLAG_REGISTRY = append(FLAG_REGISTRY, &def)
+	return &def
+}
+
+// Method to check if a flag is a switch, taking no value
+func IsBoolFlag(def *FlagDef) bool {
+	boolFlag, isBool := def.Value.(interface{ IsBoolFlag() bool })
+	return isBool && boolFlag.IsBoolFlag()
+}
+
+// Method to find a flag by its long name
+func LookupFlag(name string) *FlagDef {
+	for _, def := range FLAG_REGISTRY {
+		if def.Name == name {
+			return def
+		}
+	}
+	return nil
+}
+
+// Method to find a flag by one of its aliases
+func LookupAlias(alias string) *FlagDef {
+	for _, def := range FLAG_REGISTRY {
+		for _, candidate := range def.Aliases {
+			if candidate == alias {
+				return def
+			}
+		}
+	}
+	return nil
+}
+
+// Method to list the arguments a subcommand takes, e.g. validate and show
+func (command CommandDef) ArgChoices() []string {
+	if command.Args == "" {
+		return nil
+	}
+	return strings.Split(command.Args, " | ")
+}
+
+// Method to find a subcommand
+func LookupCommand(name string) *CommandDef {
+	for i := range COMMANDS {
+		if COMMANDS[i].Name == name {
+			return &COMMANDS[i]
+		}
+	}
+	return nil
+}
+
+// Method to measure how many edits turn one word into another
+func EditDistance(first string, second string) int {
+	// Initialise variables, keeping only the previous row
+	previous := make([]int, len(second)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(first); i++ {
+		current := make([]int, len(second)+1)
+		current[0] = i
+		for j := 1; j <= len(second); j++ {
+			cost := 1
+			if first[i-1] == second[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(second)]
+}
+
+// Method to suggest the closest of a list of words, if close enough
+func Suggest(word string, candidates []string) string {
+	// Initialise variables
+	var best string
+	bestDistance := max(2, len(word)/3) + 1
+
+	for _, candidate := range candidates {
+		distance := EditDistance(word, candidate)
+		if strings.HasPrefix(candidate, word) && len(word) >= 3 {
+			distance = 1
+		}
+		if distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// Method to build the error for an unknown flag, suggesting a known one
+func UnknownFlagError(arg string, name string) error {
+	// Initialise variables
+	var spellings []string
+	for _, def := range FLAG_REGISTRY {
+		spellings = append(spellings, "--"+def.Name)
+		for _, alias := range def.Aliases {
+			spellings = append(spellings, "-"+alias)
+		}
+	}
+
+	// The right name with the wrong number of dashes is the most likely mistake
+	switch {
+	case LookupFlag(name) != nil:
+		return errors.New("unknown flag [" + arg + "], did you mean [--" + name + "]?")
+	case LookupAlias(name) != nil:
+		return errors.New("unknown flag [" + arg + "], did you mean [-" + name + "]?")
+	}
+	if suggestion := Suggest(strings.SplitN(arg, "=", 2)[0], spellings); suggestion != "" {
+		return errors.New("unknown flag [" + arg + "], did you mean [" + suggestion + "]?")
+	}
+	return errors.New("unknown flag [" + arg + "] (see --help)")
+}
+
+// Method to parse the command line into the subcommand, its arguments, and flags
+//
+// Long names take two dashes (--offline), aliases one (-oo). Values follow the flag,
+// or are attached with = (--proxy=http://...). Flags may come before or after the
+// subcommand, and -- ends the flags.
+func ParseCommandLine(args []string) (string, []string, error) {
+	// Initialise variables
+	var positionals []string
+
+	for i := 0; i < len(args); i++ {
+		// Initialise variables
+		var def *FlagDef
+		arg := args[i]
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+
+		switch {
+		case arg == "--":
+			positionals = append(positionals, args[i+1:]...)
+			i = len(args)
+			continue
+		case strings.HasPrefix(arg, "--"):
+			def = LookupFlag(name)
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			def = LookupAlias(name)
+		default:
+			positionals = append(positionals, arg)
+			continue
+		}
+		if def == nil {
+			return "", nil, UnknownFlagError(arg, name)
+		}
+
+		// Switches are set without a value, others take the next argument if not attached
+		switch {
+		case IsBoolFlag(def) && !hasValue:
+			value = "true"
+		case !hasValue:
+			if i+1 >= len(args) {
+				return "", nil, errors.New("flag [" + arg + "] needs a value " + def.ArgName)
+			}
+			i++
+			value = args[i]
+		}
+		if err := def.Value.Set(value); err != nil {
+			return "", nil, errors.New("invalid value [" + value + "] for flag [" + arg + "]: " + err.Error())
+		}
+		def.Set = true
+	}
+
+	// Find the subcommand, suggesting a known one if misspelt
+	command := DEFAULT_COMMAND
+	if len(positionals) > 0 {
+		command = positionals[0]
+		positionals = positionals[1:]
+	}
+	if LookupCommand(command) == nil {
+		var names []string
+		for _, known := range COMMANDS {
+			names = append(names, known.Name)
+		}
+		if suggestion := Suggest(command, names); suggestion != "" {
+			return "", nil, errors.New("unknown command [" + command + "], did you mean [" + suggestion + "]?")
+		}
+		return "", nil, errors.New("unknown command [" + command + "] (see --help)")
+	}
+
+	// Refuse flags meant for other subcommands
+	for _, def := range FLAG_REGISTRY {
+		if def.Set && len(def.Commands) > 0 && !slices.Contains(def.Commands, command) {
+			return "", nil, errors.New("flag [--" + def.Name + "] only applies to [" + strings.Join(def.Commands, "], [") + "]")
+		}
+	}
+	return command, positionals, nil
+}
+
+// Method to format the spellings of a flag, e.g. "--manual-all, -ma"
+func FlagSpelling(def *FlagDef) string {
+	spelling := "--" + def.Name
+	for _, alias := range def.Aliases {
+		spelling += ", -" + alias
+	}
+	if def.ArgName != "" {
+		spelling += " " + def.ArgName
+	}
+	return spelling
+}
+
+// Prints Flags statement, generated from the flag registry
+func PrintFlags(verbosity int) {
+	// Find the widest spelling, to align descriptions
+	width := 0
+	for _, def := range FLAG_REGISTRY {
+		width = max(width, len(FlagSpelling(def)))
+	}
+
+	for _, group := range FLAG_GROUPS {
+		if verbosity >= 1 {
+			fmt.Println(group + ":")
+		}
+		for _, def := range FLAG_REGISTRY {
+			if def.Group != group {
+				continue
+			}
+			usage := def.Usage
+			if len(def.Commands) > 0 {
+				usage += " [" + strings.Join(def.Commands, ", ") + "]"
+			}
+			if def.ArgName != "" && def.Default != "" && def.Default != "0" && def.Default != "0s" {
+				usage += " (default " + def.Default + ")"
+			}
+			fmt.Printf("\t%-*s : %s\n", width, FlagSpelling(def), usage)
+		}
+	}
+}
+
+// Prints the available subcommands
+func PrintCommands() {
+	// Find the widest command, to align descriptions
+	width := 0
+	for _, command := range COMMANDS {
+		width = max(width, len(strings.TrimSpace(command.Name+" "+command.Args)))
+	}
+	for _, command := range COMMANDS {
+		fmt.Printf("\t%-*s : %s\n", width, strings.TrimSpace(command.Name+" "+command.Args), command.Usage)
+	}
+}
@@ -0,0 +1,68 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Tests of the command line parser, against the registered flags
+
+package main
+
+// Import packages
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCommandLine(t *testing.T) {
+	tests := []struct {
+		name            string
+		args            []string
+		wantCommand     string
+		wantPositionals []string
+		wantErr         string
+		check           func() bool
+	}{
+		{name: "default command", args: nil, wantCommand: "update"},
+		{name: "long name", args: []string{"--manual-all"}, wantCommand: "update", check: func() bool { return allManualFlag }},
+		{name: "alias", args: []string{"-oo", "check"}, wantCommand: "check", check: func() bool { return officialOnlyFlag }},
+		{name: "alias with two dashes", args: []string{"--ma"}, wantErr: "did you mean [-ma]?"},
+		{name: "long name with one dash", args: []string{"-offline"}, wantErr: "did you mean [--offline]?"},
+		{name: "misspelt flag", args: []string{"--oficial-only"}, wantErr: "did you mean [--official-only]?"},
+		{name: "unknown flag", args: []string{"--zzzzzzzzzzzz"}, wantErr: "(see --help)"},
+		{name: "attached value", args: []string{"--network-timeout=3s"}, wantCommand: "update", check: func() bool { return networkTimeout == 3*time.Second }},
+		{name: "separate value", args: []string{"--reboot", "always"}, wantCommand: "update", check: func() bool { return rebootPolicy == "always" }},
+		{name: "attached switch value", args: []string{"--offline=false"}, wantCommand: "update", check: func() bool { return !offlineFlag }},
+		{name: "missing value", args: []string{"--proxy"}, wantErr: "needs a value <url>"},
+		{name: "invalid value", args: []string{"--retries", "many"}, wantErr: "invalid value [many] for flag [--retries]"},
+		{name: "repeated list flag", args: []string{"-cd", "example.com", "--custom-domain=example.org"}, wantCommand: "update", check: func() bool {
+			return reflect.DeepEqual(networkTargets, []string{"example.com", "example.org"})
+		}},
+		{name: "flags after command", args: []string{"completion", "bash", "--debug"}, wantCommand: "completion", wantPositionals: []string{"bash"}, check: func() bool { return debugFlag }},
+		{name: "double dash ends flags", args: []string{"config", "--", "--effective"}, wantCommand: "config", wantPositionals: []string{"--effective"}, check: func() bool { return !effectiveFlag }},
+		{name: "misspelt command", args: []string{"chekc"}, wantErr: "did you mean [check]?"},
+		{name: "command-specific flag", args: []string{"history", "--limit", "5"}, wantCommand: "history", check: func() bool { return historyLimit == 5 }},
+		{name: "command-specific flag elsewhere", args: []string{"check", "--effective"}, wantErr: "only applies to [config]"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer ResetFlags()
+
+			command, positionals, err := ParseCommandLine(test.args)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if command != test.wantCommand || strings.Join(positionals, " ") != strings.Join(test.wantPositionals, " ") {
+				t.Errorf("ParseCommandLine(%q) = %q, %q, want %q, %q", test.args, command, positionals, test.wantCommand, test.wantPositionals)
+			}
+			if test.check != nil && !test.check() {
+				t.Errorf("ParseCommandLine(%q) did not set the flag as expected", test.args)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Read-only subcommands: check and list-managers
+
+package main
+
+// Import packages
+import (
+	"fmt"
+	"strings"
+)
+
+// // Exit code of the check command when updates are pending
+const EXIT_UPDATES_PENDING int = 100
+
+// A package manager found on the system
+type DetectedManager struct {
+	PkgNum   int
+	Official bool
+}
+
+// Method to find the package managers an update would use, honouring -ao, -oo, -yu and --managers
+//
+// As when updating, only the first official package manager found is used.
+func DetectedManagers(aoFlag bool, ooFlag bool, yFlag bool) []DetectedManager {
+	// Initialise variables
+	var managers []DetectedManager
+
+	// Official package managers
+	switch aoFlag {
+	case false:
+		for pkgNum := 0; pkgNum < OF_PKG_NUM; pkgNum++ {
+			if !PkgManCheck(pkgNum, true) {
+				continue
+			}
+			// Add exception for Yum, if Dnf exists
+			if pkgNum == 1 && yFlag && PkgManCheck(4, true) {
+				pkgNum = 4
+			}
+			if ManagerChosen(pkgNum, true) {
+				managers = append(managers, DetectedManager{PkgNum: pkgNum, Official: true})
+			}
+			break
+		}
+	}
+
+	// Alternative package managers
+	switch ooFlag {
+	case false:
+		for pkgNum := 0; pkgNum < AL_PKG_NUM; pkgNum++ {
+			if PkgManCheck(pkgNum, false) && ManagerChosen(pkgNum, false) {
+				managers = append(managers, DetectedManager{PkgNum: pkgNum, Official: false})
+			}
+		}
+	}
+	return managers
+}
+
+// Method to run the check command: test connectivity and repositories, and list pending updates
+//
+// Exits with 100 if any update is pending, so scripts and monitoring can act on it.
+func CheckCommand() int {
+	// Initialise variables
+	var pending int
+	var failed bool
+	// Proxy settings are written to temporary files, which hold any password
+	defer ProxyCleanup()
+
+	if err := ActionsForFlags(altOnlyFlag, officialOnlyFlag, networkTargets); err != nil {
+		PrintFailure(err)
+		return 1
+	}
+
+	for _, detected := range DetectedManagers(altOnlyFlag, officialOnlyFlag, yumUpdateFlag) {
+		name := PkgManagerName(detected.PkgNum, detected.Official)
+		if _, listable := SelectionStep(detected.PkgNum, detected.Official); !listable {
+			PrintWarning("\t* [" + name + "] cannot list pending updates, skipped")
+			continue
+		}
+		// Listing needs no privileges, so every scope runs as the current user
+		for _, scope := range PrivilegeScopes(detected.PkgNum, detected.Official) {
+			// Initialise variables
+			label := name
+			if scope.Label != "" {
+				label += " (" + scope.Label + ")"
+			}
+
+			path, err := ResolveManager(name, false, scope.Account)
+			if err != nil {
+				PrintFailure("!!Could NOT find ["+label+"]:", err)
+				failed = true
+				continue
+			}
+			manager := ScopedManager{Scope: PrivilegeScope{Label: scope.Label, Account: scope.Account}, Path: path}
+			proxyArgs, proxyEnv, _ := ProxyArgs(detected.PkgNum, detected.Official)
+			packages, stderr, err := ListPendingUpdates(manager, detected.PkgNum, detected.Official, append(append([]string{}, scope.Args...), proxyArgs...), proxyEnv)
+			if err != nil {
+				PrintFailure("!!Could NOT list pending updates for ["+label+"]:", err)
+				if len(stderr) > 0 {
+					PrintVerbose(strings.TrimSpace(string(stderr)))
+				}
+				failed = true
+				continue
+			}
+			logger.Info("pending updates listed", "manager", label, "count", len(packages))
+			switch len(packages) {
+			case 0:
+				PrintSuccess("\t* [" + label + "] is up to date")
+			default:
+				PrintWarning("\t* [" + label + "] has " + fmt.Sprint(len(packages)) + " pending update(s)")
+				for _, pkg := range packages {
+					PrintOutput([]byte("\t\t" + pkg + "\n"))
+				}
+			}
+			pending += len(packages)
+		}
+	}
+
+	switch {
+	case failed:
+		return 1
+	case pending > 0:
+		return EXIT_UPDATES_PENDING
+	}
+	return 0
+}
+
+// Method to run the list-managers command: every supported package manager, and which are detected
+func ListManagersCommand() int {
+	fmt.Printf("%-22s %-12s %-10s %-9s %-7s %s\n", "MANAGER", "TYPE", "PRIVILEGE", "DETECTED", "SELECT", "SECURITY")
+	for _, official := range []bool{true, false} {
+		// Initialise variables
+		count, kind := OF_PKG_NUM, "official"
+		if !official {
+			count, kind = AL_PKG_NUM, "alternative"
+		}
+		for pkgNum := 0; pkgNum < count; pkgNum++ {
+			// Initialise variables
+			_, selectable := SelectionStep(pkgNum, official)
+			_, _, security := SecurityArgs(pkgNum, official, 0)
+			privilege := "root"
+			switch ManagerPrivilege(pkgNum, official) {
+			case PRIV_USER:
+				privilege = "user"
+			case PRIV_BOTH:
+				privilege = "both"
+			}
+			detected := PkgManCheck(pkgNum, official)
+			detectedText := fmt.Sprintf("%-9s", YesNo(detected))
+			if detected {
+				detectedText = Colorize(COLOR_GREEN, detectedText)
+			}
+			fmt.Printf("%-22s %-12s %-10s %s %-7s %s\n", PkgManagerName(pkgNum, official), kind, privilege, detectedText, YesNo(selectable), YesNo(security))
+		}
+	}
+	return 0
+}
+
+// Method to print a boolean as yes or no
+func YesNo(value bool) string {
+	switch value {
+	case true:
+		return "yes"
+	default:
+		return "no"
+	}
+}
@@ -0,0 +1,305 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Shell completion scripts, generated from the subcommands and the flag registry
+
+package main
+
+// Import packages
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// // Names update_full is installed as, given completion
+var COMPLETION_PROGRAMS []string = []string{"update_full", "update_full-go"}
+
+// // Shells completion scripts are generated for
+var COMPLETION_SHELLS []string = []string{"bash", "zsh", "fish"}
+
+// Method to run the completion command, printing the script for a shell
+//
+// Profile names are read from the configuration when the script is generated.
+func CompletionCommand(config *Config, args []string) int {
+	// Initialise variables
+	var script string
+	if len(args) != 1 {
+		fmt.Println("!!Usage: completion " + strings.Join(COMPLETION_SHELLS, " | "))
+		return 1
+	}
+
+	switch args[0] {
+	case "bash":
+		script = BashCompletion(config)
+	case "zsh":
+		script = ZshCompletion(config)
+	case "fish":
+		script = FishCompletion(config)
+	default:
+		fmt.Println("!!Unsupported shell [" + args[0] + "] (" + strings.Join(COMPLETION_SHELLS, ", ") + ")")
+		return 1
+	}
+	fmt.Print(script)
+	return 0
+}
+
+// Method to check if a flag completes file names
+func CompletesFiles(def *FlagDef) bool {
+	return def.ArgName == "<path>"
+}
+
+// Method to check if a flag can be given more than once
+func IsRepeatableFlag(def *FlagDef) bool {
+	_, isList := def.Value.(StringListFlag)
+	return isList
+}
+
+// Method to list the names of every subcommand
+func CommandNames() []string {
+	// Initialise variables
+	var names []string
+	for _, command := range COMMANDS {
+		names = append(names, command.Name)
+	}
+	return names
+}
+
+// Method to list the spellings of the flags accepted by a subcommand (every subcommand, if empty)
+func FlagSpellings(command string) []string {
+	// Initialise variables
+	var spellings []string
+	for _, def := range FLAG_REGISTRY {
+		if command != "" && len(def.Commands) > 0 && !slices.Contains(def.Commands, command) {
+			continue
+		}
+		spellings = append(spellings, "--"+def.Name)
+		for _, alias := range def.Aliases {
+			spellings = append(spellings, "-"+alias)
+		}
+	}
+	return spellings
+}
+
+// Method to list every spelling of a flag, long name first
+func AllSpellings(def *FlagDef) []string {
+	spellings := []string{"--" + def.Name}
+	for _, alias := range def.Aliases {
+		spellings = append(spellings, "-"+alias)
+	}
+	return spellings
+}
+
+// Method to generate the bash completion script
+func BashCompletion(config *Config) string {
+	// Initialise variables
+	var script strings.Builder
+	var valueFlags []string
+
+	script.WriteString("# bash completion for update_full, generated by: update_full completion bash\n")
+	script.WriteString("_update_full() {\n")
+	script.WriteString("\tlocal cur prev command i\n")
+	script.WriteString("\tcur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	script.WriteString("\tprev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	// --flag=value is split at the = by bash
+	script.WriteString("\tif [[ \"$prev\" == \"=\" ]]; then\n\t\tprev=\"${COMP_WORDS[COMP_CWORD-2]}\"\n\telif [[ \"$cur\" == \"=\" ]]; then\n\t\tcur=\"\"\n\tfi\n")
+
+	// Complete the values of flags
+	script.WriteString("\tcase \"$prev\" in\n")
+	for _, def := range FLAG_REGISTRY {
+		if IsBoolFlag(def) {
+			continue
+		}
+		spellings := AllSpellings(def)
+		valueFlags = append(valueFlags, spellings...)
+		switch {
+		case def.Choices != nil && IsRepeatableFlag(def):
+			// Comma-separated lists complete their last item
+			fmt.Fprintf(&script, "\t%s)\n\t\tCOMPREPLY=($(compgen -P \"${cur%%\"${cur##*,}\"}\" -W %s -- \"${cur##*,}\"))\n\t\treturn ;;\n", strings.Join(spellings, "|"), ShellQuote(strings.Join(def.Choices(config), " ")))
+		case def.Choices != nil:
+			fmt.Fprintf(&script, "\t%s)\n\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\"))\n\t\treturn ;;\n", strings.Join(spellings, "|"), ShellQuote(strings.Join(def.Choices(config), " ")))
+		case CompletesFiles(def):
+			fmt.Fprintf(&script, "\t%s)\n\t\tCOMPREPLY=($(compgen -f -- \"$cur\"))\n\t\treturn ;;\n", strings.Join(spellings, "|"))
+		default:
+			fmt.Fprintf(&script, "\t%s)\n\t\treturn ;;\n", strings.Join(spellings, "|"))
+		}
+	}
+	script.WriteString("\tesac\n")
+
+	// Find the subcommand, skipping flags and their values
+	script.WriteString("\tcommand=\"\"\n")
+	script.WriteString("\tfor ((i = 1; i < COMP_CWORD; i++)); do\n")
+	script.WriteString("\t\tcase \"${COMP_WORDS[i]}\" in\n")
+	fmt.Fprintf(&script, "\t\t%s)\n\t\t\t[[ \"${COMP_WORDS[i+1]}\" == \"=\" ]] && ((i++))\n\t\t\t((i++)) ;;\n", strings.Join(valueFlags, "|"))
+	script.WriteString("\t\t=|-*) ;;\n")
+	script.WriteString("\t\t*)\n\t\t\tcommand=\"${COMP_WORDS[i]}\"\n\t\t\tbreak ;;\n")
+	script.WriteString("\t\tesac\n")
+	script.WriteString("\tdone\n")
+
+	// Complete flags accepted by the subcommand
+	script.WriteString("\tif [[ \"$cur\" == -* ]]; then\n")
+	script.WriteString("\t\tcase \"$command\" in\n")
+	for _, command := range COMMANDS {
+		fmt.Fprintf(&script, "\t\t%s)\n\t\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\")) ;;\n", command.Name, ShellQuote(strings.Join(FlagSpellings(command.Name), " ")))
+	}
+	fmt.Fprintf(&script, "\t\t*)\n\t\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\")) ;;\n", ShellQuote(strings.Join(FlagSpellings(DEFAULT_COMMAND), " ")))
+	script.WriteString("\t\tesac\n")
+	script.WriteString("\t\treturn\n")
+	script.WriteString("\tfi\n")
+
+	// Complete the subcommand, then its arguments
+	script.WriteString("\tcase \"$command\" in\n")
+	fmt.Fprintf(&script, "\t\"\")\n\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\")) ;;\n", ShellQuote(strings.Join(CommandNames(), " ")))
+	for _, command := range COMMANDS {
+		if choices := command.ArgChoices(); len(choices) > 0 {
+			fmt.Fprintf(&script, "\t%s)\n\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\")) ;;\n", command.Name, ShellQuote(strings.Join(choices, " ")))
+		}
+	}
+	script.WriteString("\tesac\n")
+	script.WriteString("}\n")
+	script.WriteString("complete -F _update_full " + strings.Join(COMPLETION_PROGRAMS, " ") + "\n")
+	return script.String()
+}
+
+// Method to generate the zsh completion script
+func ZshCompletion(config *Config) string {
+	// Initialise variables
+	var script strings.Builder
+
+	script.WriteString("#compdef " + strings.Join(COMPLETION_PROGRAMS, " ") + "\n")
+	script.WriteString("# zsh completion for update_full, generated by: update_full completion zsh\n\n")
+	script.WriteString("_update_full() {\n")
+	script.WriteString("\tlocal context state state_descr line\n")
+	script.WriteString("\ttypeset -A opt_args\n")
+
+	// Subcommands, with their descriptions
+	script.WriteString("\tlocal -a commands=(\n")
+	for _, command := range COMMANDS {
+		script.WriteString("\t\t" + ShellQuote(command.Name+":"+command.Usage) + "\n")
+	}
+	script.WriteString("\t)\n")
+
+	// Flags accepted by every subcommand, then those of single subcommands
+	script.WriteString("\tlocal -a flags=(\n")
+	for _, def := range FLAG_REGISTRY {
+		if len(def.Commands) == 0 {
+			for _, spec := range ZshFlagSpecs(config, def) {
+				script.WriteString("\t\t" + spec + "\n")
+			}
+		}
+	}
+	script.WriteString("\t)\n")
+
+	script.WriteString("\t_arguments -C -S $flags '1:command:->command' '*::argument:->argument' && return\n")
+	script.WriteString("\tcase $state in\n")
+	script.WriteString("\tcommand)\n\t\t_describe -t commands 'update_full command' commands ;;\n")
+	script.WriteString("\targument)\n")
+	script.WriteString("\t\tcase $line[1] in\n")
+	for _, command := range COMMANDS {
+		// Initialise variables
+		var specs []string
+		for _, def := range FLAG_REGISTRY {
+			if slices.Contains(def.Commands, command.Name) {
+				specs = append(specs, ZshFlagSpecs(config, def)...)
+			}
+		}
+		if choices := command.ArgChoices(); len(choices) > 0 {
+			specs = append(specs, ShellQuote("1:"+strings.ReplaceAll(command.Args, " | ", " or ")+":("+strings.Join(choices, " ")+")"))
+		}
+		if len(specs) == 0 {
+			continue
+		}
+		fmt.Fprintf(&script, "\t\t%s)\n\t\t\t_arguments -S $flags %s ;;\n", command.Name, strings.Join(specs, " "))
+	}
+	script.WriteString("\t\t*)\n\t\t\t_arguments -S $flags ;;\n")
+	script.WriteString("\t\tesac ;;\n")
+	script.WriteString("\tesac\n")
+	script.WriteString("}\n\n")
+	script.WriteString("_update_full \"$@\"\n")
+	return script.String()
+}
+
+// Method to build the _arguments specifications of a flag, one per spelling
+func ZshFlagSpecs(config *Config, def *FlagDef) []string {
+	// Initialise variables
+	var specs []string
+	spellings := AllSpellings(def)
+	description := strings.NewReplacer("[", "\\[", "]", "\\]").Replace(def.Usage)
+
+	// Flags given once exclude their other spellings
+	prefix := "(" + strings.Join(spellings, " ") + ")"
+	if IsRepeatableFlag(def) {
+		prefix = "*"
+	}
+	for _, spelling := range spellings {
+		spec := prefix + spelling
+		if !IsBoolFlag(def) {
+			// Long names also take --name=value
+			if strings.HasPrefix(spelling, "--") {
+				spec += "="
+			}
+			spec += "[" + description + "]:" + strings.Trim(def.ArgName, "<>") + ":"
+			switch {
+			case def.Choices != nil && IsRepeatableFlag(def):
+				spec += "_sequence compadd - " + strings.Join(def.Choices(config), " ")
+			case def.Choices != nil:
+				spec += "(" + strings.Join(def.Choices(config), " ") + ")"
+			case CompletesFiles(def):
+				spec += "_files"
+			}
+		} else {
+			spec += "[" + description + "]"
+		}
+		specs = append(specs, ShellQuote(spec))
+	}
+	return specs
+}
+
+// Method to generate the fish completion script
+func FishCompletion(config *Config) string {
+	// Initialise variables
+	var script strings.Builder
+	commands := strings.Join(CommandNames(), " ")
+
+	script.WriteString("# fish completion for update_full, generated by: update_full completion fish\n")
+	script.WriteString("for program in " + strings.Join(COMPLETION_PROGRAMS, " ") + "\n")
+	script.WriteString("\tcomplete -c $program -f\n")
+
+	// Subcommands, then their arguments
+	for _, command := range COMMANDS {
+		fmt.Fprintf(&script, "\tcomplete -c $program -n %s -a %s -d %s\n", ShellQuote("not __fish_seen_subcommand_from "+commands), command.Name, ShellQuote(command.Usage))
+		if choices := command.ArgChoices(); len(choices) > 0 {
+			fmt.Fprintf(&script, "\tcomplete -c $program -n %s -a %s\n", ShellQuote("__fish_seen_subcommand_from "+command.Name), ShellQuote(strings.Join(choices, " ")))
+		}
+	}
+
+	// Flags, limited to their subcommands
+	for _, def := range FLAG_REGISTRY {
+		line := "\tcomplete -c $program"
+		if len(def.Commands) > 0 {
+			line += " -n " + ShellQuote("__fish_seen_subcommand_from "+strings.Join(def.Commands, " "))
+		}
+		line += " -l " + def.Name
+		for _, alias := range def.Aliases {
+			line += " -o " + alias
+		}
+		if !IsBoolFlag(def) {
+			line += " -r"
+			switch {
+			case def.Choices != nil:
+				line += " -a " + ShellQuote(strings.Join(def.Choices(config), " "))
+			case CompletesFiles(def):
+				line += " -F"
+			}
+		}
+		line += " -d " + ShellQuote(def.Usage)
+		script.WriteString(line + "\n")
+	}
+	script.WriteString("end\n")
+	return script.String()
+}
+
+// Method to quote a word for bash, zsh and fish, in single quotes
+func ShellQuote(word string) string {
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
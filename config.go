@@ -0,0 +1,546 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Configuration files and UPDATE_FULL_* environment variables
+//
+// Precedence, lowest first: defaults, system file, per-user file, the chosen profile,
+// UPDATE_FULL_* variables, command-line flags. Within a file, a [host.<hostname>] table
+// overrides the top level on that host. UPDATE_FULL_CONFIG or --config replace both files
+// with a single one.
+
+package main
+
+// Import packages
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// // Configuration locations
+const CONFIG_SYSTEM_PATH string = "/etc/update_full/config.toml"
+const CONFIG_ENV_VAR string = "UPDATE_FULL_CONFIG"
+const CONFIG_ENV_PREFIX string = "UPDATE_FULL_"
+
+// // UPDATE_FULL_* variables set by update_full for hooks, rather than options
+var CONFIG_ENV_IGNORED []string = []string{CONFIG_ENV_VAR, "UPDATE_FULL_PHASE", "UPDATE_FULL_RESULT"}
+
+// // Sources of settings, besides configuration files
+const SOURCE_DEFAULT string = "default"
+const SOURCE_FLAG string = "command line"
+
+// A single key = value line of a configuration file
+type ConfigEntry struct {
+	Table  string
+	Key    string
+	Values []string
+	IsList bool
+	Line   int
+}
+
+// A setting found in a configuration file or environment variable
+type ConfigSetting struct {
+	Values []string
+	IsList bool
+	Source string
+}
+
+// Merged configuration, by long flag name
+type Config struct {
+	Files        []string
+	Settings     map[string]ConfigSetting
+	Sources      map[string]string
+	Profiles     map[string]map[string]ConfigSetting
+	Profile      string
+	ProfileChain []string
+}
+
+// Method to parse a (minimal) TOML value: strings, booleans, numbers, and arrays of those
+func ParseConfigValue(value string) ([]string, bool, error) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "[") {
+		parsed, rest, err := ParseConfigScalar(value)
+		if err != nil {
+			return nil, false, err
+		}
+		if strings.TrimSpace(rest) != "" {
+			return nil, false, errors.New("unexpected [" + strings.TrimSpace(rest) + "] after value")
+		}
+		return []string{parsed}, false, nil
+	}
+
+	// Arrays, possibly spanning lines, with an optional trailing comma
+	var values []string
+	rest := strings.TrimSpace(value[1:])
+	for {
+		rest = strings.TrimSpace(rest)
+		if strings.HasPrefix(rest, "]") {
+			if strings.TrimSpace(rest[1:]) != "" {
+				return nil, false, errors.New("unexpected [" + strings.TrimSpace(rest[1:]) + "] after array")
+			}
+			return values, true, nil
+		}
+		parsed, remaining, err := ParseConfigScalar(rest)
+		if err != nil {
+			return nil, false, err
+		}
+		values = append(values, parsed)
+		rest = strings.TrimSpace(remaining)
+		switch {
+		case strings.HasPrefix(rest, ","):
+			rest = rest[1:]
+		case strings.HasPrefix(rest, "]"):
+		default:
+			return nil, false, errors.New("expected [,] or []] in array")
+		}
+	}
+}
+
+// Method to parse a single TOML string, boolean or number, returning what follows it
+func ParseConfigScalar(value string) (string, string, error) {
+	switch {
+	// Basic strings, with escapes
+	case strings.HasPrefix(value, "\""):
+		var parsed strings.Builder
+		for i := 1; i < len(value); i++ {
+			switch value[i] {
+			case '"':
+				return parsed.String(), value[i+1:], nil
+			case '\\':
+				if i+1 >= len(value) {
+					return "", "", errors.New("unterminated escape")
+				}
+				i++
+				switch value[i] {
+				case 'n':
+					parsed.WriteByte('\n')
+				case 't':
+					parsed.WriteByte('\t')
+				case '"', '\\':
+					parsed.WriteByte(value[i])
+				default:
+					return "", "", errors.New("unsupported escape [\\" + string(value[i]) + "]")
+				}
+			default:
+				parsed.WriteByte(value[i])
+			}
+		}
+		return "", "", errors.New("unterminated string")
+	// Literal strings, without escapes
+	case strings.HasPrefix(value, "'"):
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return "", "", errors.New("unterminated string")
+		}
+		return value[1 : end+1], value[end+2:], nil
+	// Booleans and numbers, ending at a separator
+	default:
+		end := strings.IndexAny(value, ",] \t")
+		if end < 0 {
+			end = len(value)
+		}
+		token := value[:end]
+		if token == "true" || token == "false" {
+			return token, value[end:], nil
+		}
+		if _, err := strconv.ParseFloat(strings.ReplaceAll(token, "_", ""), 64); err == nil && token != "" {
+			return strings.ReplaceAll(token, "_", ""), value[end:], nil
+		}
+		return "", "", errors.New("invalid value [" + token + "] (strings must be quoted)")
+	}
+}
+
+// Method to remove a comment from a line, outside of strings
+func StripConfigComment(line string) string {
+	// Initialise variables
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch {
+		case quote == '"' && line[i] == '\\':
+			i++
+		case quote != 0:
+			if line[i] == quote {
+				quote = 0
+			}
+		case line[i] == '"' || line[i] == '\'':
+			quote = line[i]
+		case line[i] == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// Method to parse a configuration file into its entries
+func ParseConfig(data string) ([]ConfigEntry, error) {
+	// Initialise variables
+	var entries []ConfigEntry
+	var table string
+	lines := strings.Split(data, "\n")
+
+	for number := 0; number < len(lines); number++ {
+		line := strings.TrimSpace(StripConfigComment(lines[number]))
+		lineNumber := number + 1
+		if line == "" {
+			continue
+		}
+
+		// Tables
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: invalid table [%s]", lineNumber, line)
+			}
+			table = strings.ReplaceAll(strings.TrimSpace(line[1:len(line)-1]), "\"", "")
+			continue
+		}
+
+		// Keys and values
+		key, value, found := strings.Cut(line, "=")
+		key = strings.Trim(strings.TrimSpace(key), "\"")
+		if !found || key == "" {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNumber)
+		}
+		value = strings.TrimSpace(value)
+		// Arrays may span several lines, until the closing bracket
+		for strings.HasPrefix(value, "[") && !strings.HasSuffix(value, "]") && number+1 < len(lines) {
+			number++
+			value += " " + strings.TrimSpace(StripConfigComment(lines[number]))
+		}
+		values, isList, err := ParseConfigValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", lineNumber, key, err)
+		}
+		entries = append(entries, ConfigEntry{Table: table, Key: key, Values: values, IsList: isList, Line: lineNumber})
+	}
+	return entries, nil
+}
+
+// Method to find the configuration files to read, lowest precedence first
+func ConfigPaths(override string) []string {
+	switch {
+	case override != "":
+		return []string{override}
+	case os.Getenv(CONFIG_ENV_VAR) != "":
+		return []string{os.Getenv(CONFIG_ENV_VAR)}
+	}
+	paths := []string{CONFIG_SYSTEM_PATH}
+	if configDir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(configDir, "update_full", "config.toml"))
+	}
+	return paths
+}
+
+// Method to check if a flag can be set from configuration, by its long name
+func ConfigurableFlag(name string) bool {
+	def := LookupFlag(name)
+	return def != nil && !def.NoConfig
+}
+
+// Method to check a key of a configuration file, suggesting the long name of aliases
+func CheckConfigKey(key string) error {
+	if def := LookupAlias(key); def != nil && LookupFlag(key) == nil {
+		return errors.New("unknown option [" + key + "], use its long name [" + def.Name + "]")
+	}
+	if !ConfigurableFlag(key) {
+		// Suggest the closest option, if any
+		var names []string
+		for _, def := range FLAG_REGISTRY {
+			if !def.NoConfig {
+				names = append(names, def.Name)
+			}
+		}
+		if suggestion := Suggest(key, names); suggestion != "" {
+			return errors.New("unknown option [" + key + "], did you mean [" + suggestion + "]?")
+		}
+		return errors.New("unknown option [" + key + "]")
+	}
+	return nil
+}
+
+// Method to load configuration files, the chosen profile, and UPDATE_FULL_* variables
+//
+// Missing default files are ignored, but a file given through --config or UPDATE_FULL_CONFIG must exist.
+// The profile is the one given, or else the one set through UPDATE_FULL_PROFILE or the files.
+func LoadConfig(override string, profile string) (*Config, error) {
+	// Initialise variables
+	config := &Config{Settings: map[string]ConfigSetting{}, Sources: map[string]string{}, Profiles: map[string]map[string]ConfigSetting{}}
+	envSettings := map[string]ConfigSetting{}
+	hostname, _ := os.Hostname()
+	explicit := override != "" || os.Getenv(CONFIG_ENV_VAR) != ""
+
+	for _, path := range ConfigPaths(override) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && !explicit {
+				continue
+			}
+			return nil, err
+		}
+		entries, err := ParseConfig(string(data))
+		if err != nil {
+			return nil, errors.New(path + ": " + err.Error())
+		}
+		config.Files = append(config.Files, path)
+
+		// Top level first, then the table of this host
+		hostTable := "host." + hostname
+		for _, pass := range []string{"", hostTable} {
+			for _, entry := range entries {
+				switch {
+				// Profiles are collected once, and only applied if chosen
+				case strings.HasPrefix(entry.Table, PROFILE_TABLE_PREFIX):
+					if pass == "" {
+						if err = config.AddProfileEntry(path, entry); err != nil {
+							return nil, err
+						}
+					}
+					continue
+				case entry.Table != "" && !strings.HasPrefix(entry.Table, "host."):
+					return nil, fmt.Errorf("%s: line %d: unknown table [%s]", path, entry.Line, entry.Table)
+				}
+				if entry.Table != pass {
+					continue
+				}
+				if err = CheckConfigKey(entry.Key); err != nil {
+					return nil, fmt.Errorf("%s: line %d: %w", path, entry.Line, err)
+				}
+				source := path
+				if pass != "" {
+					source += " [" + pass + "]"
+				}
+				config.Settings[entry.Key] = ConfigSetting{Values: entry.Values, IsList: entry.IsList, Source: source}
+			}
+		}
+	}
+
+	// Environment variables override files, e.g. UPDATE_FULL_OFFICIAL_ONLY=true
+	for _, variable := range os.Environ() {
+		name, value, _ := strings.Cut(variable, "=")
+		if !strings.HasPrefix(name, CONFIG_ENV_PREFIX) || slices.Contains(CONFIG_ENV_IGNORED, name) {
+			continue
+		}
+		key := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(name, CONFIG_ENV_PREFIX), "_", "-"))
+		if err := CheckConfigKey(key); err != nil {
+			return nil, errors.New(name + ": " + err.Error())
+		}
+		// Lists are comma-separated
+		setting := ConfigSetting{Values: []string{value}, Source: "env " + name}
+		if _, isList := LookupFlag(key).Value.(StringListFlag); isList {
+			setting.Values = strings.Split(value, ",")
+			setting.IsList = true
+		}
+		envSettings[key] = setting
+	}
+
+	// Find the chosen profile: flag, then environment, then files
+	if setting, found := envSettings["profile"]; found && profile == "" {
+		profile = setting.Values[0]
+	}
+	if setting, found := config.Settings["profile"]; found && profile == "" {
+		profile = setting.Values[0]
+	}
+	// The profile overrides files, but not environment variables or flags
+	if profile != "" {
+		settings, chain, err := config.ResolveProfile(profile)
+		if err != nil {
+			return nil, err
+		}
+		for key, setting := range settings {
+			config.Settings[key] = setting
+		}
+		config.Profile = profile
+		config.ProfileChain = chain
+	}
+	for key, setting := range envSettings {
+		config.Settings[key] = setting
+	}
+	return config, nil
+}
+
+// Method to apply the configuration to flags not given on the command line
+func (config *Config) Apply() error {
+	// Find flags given on the command line
+	for _, def := range FLAG_REGISTRY {
+		switch {
+		case def.NoConfig:
+		case def.Set:
+			config.Sources[def.Name] = SOURCE_FLAG
+		default:
+			config.Sources[def.Name] = SOURCE_DEFAULT
+		}
+	}
+
+	// Apply settings in a stable order, so errors are reproducible
+	names := make([]string, 0, len(config.Settings))
+	for name := range config.Settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		setting := config.Settings[name]
+		def := LookupFlag(name)
+		if def.Set {
+			continue
+		}
+		_, isList := def.Value.(StringListFlag)
+		if setting.IsList && !isList {
+			return errors.New(setting.Source + ": " + name + ": expected a single value, not a list")
+		}
+		for _, value := range setting.Values {
+			if err := def.Value.Set(value); err != nil {
+				return errors.New(setting.Source + ": " + name + " = " + strconv.Quote(value) + ": " + err.Error())
+			}
+		}
+		config.Sources[name] = setting.Source
+	}
+	return nil
+}
+
+// Method to format the current value of a flag as TOML
+func ConfigValueString(option *FlagDef) string {
+	// Lists
+	if list, isList := option.Value.(StringListFlag); isList {
+		var quoted []string
+		if list.Values != nil {
+			for _, value := range *list.Values {
+				quoted = append(quoted, strconv.Quote(RedactSecrets(value)))
+			}
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+	value := option.Value.String()
+	// Booleans and numbers are bare
+	switch option.Value.(type) {
+	case BoolValue, IntValue:
+		return value
+	}
+	return strconv.Quote(RedactSecrets(value))
+}
+
+// Method to validate settings after flags, variables and configuration files are applied
+//
+// Settings given as text (sizes, bandwidth) are converted as well. Every problem is
+// returned, naming where the setting came from.
+func ValidateSettings(config *Config) []error {
+	// Initialise variables
+	var problems []error
+	var err error
+
+	// Add a problem about a setting, naming its source unless it is a flag or the default
+	invalid := func(name string, message string) {
+		if source := config.Sources[name]; source != "" && source != SOURCE_DEFAULT && source != SOURCE_FLAG {
+			message = source + ": " + message
+		}
+		problems = append(problems, errors.New(message))
+	}
+
+	// Output
+	if quietFlag && verboseFlag {
+		invalid("quiet", "incompatible arguments [--quiet && --verbose]")
+	}
+	if level := EffectiveLogLevel(logLevel, debugFlag, logFile); level != LOG_LEVEL_OFF {
+		if _, err = ParseLogLevel(level); err != nil {
+			invalid("log-level", err.Error())
+		}
+	}
+	if format := strings.ToLower(logFormat); format != "text" && format != "json" {
+		invalid("log-format", "invalid log format ["+logFormat+"] (text or json)")
+	}
+	// Network
+	if !ValidateEscalation(escalateFlag) {
+		invalid("escalate", "invalid escalation method ["+escalateFlag+"] (auto, sudo, doas, run0, pkexec or none)")
+	}
+	if proxyURL != "" && !ValidateProxy(proxyURL) {
+		invalid("proxy", "invalid proxy URL ["+RedactSecrets(proxyURL)+"] (scheme://host:port)")
+	}
+	if networkTimeout <= 0 {
+		invalid("network-timeout", "invalid network timeout [--network-timeout > 0]")
+	}
+	if retryAttempts < 1 || retryDelay < 0 || retryMaxDelay < retryDelay {
+		invalid("retries", "invalid retry settings [--retries >= 1, 0 <= --retry-delay <= --retry-max-delay]")
+	}
+	if maxBandwidth, err = ParseBandwidth(maxBandwidthSetting); err != nil {
+		invalid("max-bandwidth", err.Error())
+	}
+	// Policy
+	if err = ValidateManagers(); err != nil {
+		invalid("managers", "invalid package managers: "+err.Error())
+	}
+	if !ValidateRebootPolicy(rebootPolicy) {
+		invalid("reboot", "invalid reboot policy ["+rebootPolicy+"] (never, if-required or always)")
+	}
+	if !ValidateDiskPolicy(diskPolicy) {
+		invalid("disk-policy", "invalid disk space policy ["+diskPolicy+"] (abort, clean or warn)")
+	}
+	if minFreeSpace, err = ParseSize(minFreeSpaceSetting); err != nil {
+		invalid("min-free-space", "invalid disk space threshold: "+err.Error())
+	}
+	if minFreeBoot, err = ParseSize(minFreeBootSetting); err != nil {
+		invalid("min-free-boot", "invalid disk space threshold: "+err.Error())
+	}
+	if minFreeInodes < 0 || minFreeInodes > 100 {
+		invalid("min-free-inodes", "invalid free inodes [0 <= --min-free-inodes <= 100]")
+	}
+	if minBattery < 0 || minBattery > 100 || batteryWait < 0 {
+		invalid("min-battery", "invalid battery settings [0 <= --min-battery <= 100, --battery-wait >= 0]")
+	}
+	if stepTimeout < 0 {
+		invalid("step-timeout", "invalid step timeout [--step-timeout >= 0]")
+	}
+	return problems
+}
+
+// // Whether config show prints every option, set by flags
+var effectiveFlag bool
+
+// Method to run the config command: config validate | config show [--effective]
+func RunConfigCommand(config *Config, args []string) int {
+	// Initialise variables
+	var action string
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	if len(args) > 1 {
+		fmt.Println("!!Too many arguments for config [" + strings.Join(args[1:], "] [") + "]")
+		return 1
+	}
+	switch action {
+	case "validate":
+		if len(config.Files) == 0 {
+			fmt.Println("* No configuration file found (" + strings.Join(ConfigPaths(""), ", ") + ")")
+		}
+		// Values are checked as applied, along with variables and flags
+		if problems := ValidateSettings(config); len(problems) > 0 {
+			for _, problem := range problems {
+				fmt.Println("!!" + problem.Error())
+			}
+			return 1
+		}
+		for _, path := range config.Files {
+			fmt.Println("* Configuration file [" + path + "] is valid")
+		}
+		return 0
+	case "show":
+		fmt.Println("# Configuration files: " + strings.Join(config.Files, ", "))
+		if config.Profile != "" {
+			fmt.Println("# Profile: " + strings.Join(config.ProfileChain, " < "))
+		}
+		for _, option := range FLAG_REGISTRY {
+			source, found := config.Sources[option.Name]
+			if !found || (!effectiveFlag && source == SOURCE_DEFAULT) {
+				continue
+			}
+			fmt.Println(option.Name + " = " + ConfigValueString(option) + " # " + source)
+		}
+		return 0
+	default:
+		fmt.Println("!!Unknown config command [" + action + "] (validate, or show [--effective])")
+		return 1
+	}
+}
@@ -0,0 +1,170 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Tests of configuration files: the TOML parser, loading, and validation of settings
+
+package main
+
+// Import packages
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Method to register every flag once, before any test runs
+func TestMain(m *testing.M) {
+	RegisterFlags()
+	os.Exit(m.Run())
+}
+
+// Method to reset every flag to its default, after a test changed them
+func ResetFlags() {
+	for _, def := range FLAG_REGISTRY {
+		def.Set = false
+		if list, isList := def.Value.(StringListFlag); isList {
+			*list.Values = nil
+			continue
+		}
+		def.Value.Set(def.Default)
+	}
+}
+
+func TestParseConfigValue(t *testing.T) {
+	tests := []struct {
+		value      string
+		wantValues []string
+		wantList   bool
+		wantErr    bool
+	}{
+		{`"basic \"quoted\"\tstring"`, []string{"basic \"quoted\"\tstring"}, false, false},
+		{`'C:\literal'`, []string{`C:\literal`}, false, false},
+		{`true`, []string{"true"}, false, false},
+		{`1_000`, []string{"1000"}, false, false},
+		{`-2.5`, []string{"-2.5"}, false, false},
+		{`["apt", 'flatpak', ]`, []string{"apt", "flatpak"}, true, false},
+		{`[]`, nil, true, false},
+		{`bare`, nil, false, true},
+		{`"unterminated`, nil, false, true},
+		{`"bad \q escape"`, nil, false, true},
+		{`"a" "b"`, nil, false, true},
+		{`["a" "b"]`, nil, false, true},
+		{`["a"] x`, nil, false, true},
+	}
+	for _, test := range tests {
+		values, isList, err := ParseConfigValue(test.value)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseConfigValue(%s) error = %v, want error %v", test.value, err, test.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(values, test.wantValues) || isList != test.wantList {
+			t.Errorf("ParseConfigValue(%s) = %q, %v, want %q, %v", test.value, values, isList, test.wantValues, test.wantList)
+		}
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantEntries []ConfigEntry
+		wantErr     string
+	}{
+		{
+			name: "keys, tables and comments",
+			data: "# update_full\n" +
+				"official-only = true # trailing comment\n" +
+				"proxy = \"http://proxy#1:3128\"\n" +
+				"\n" +
+				"[host.\"build-01\"]\n" +
+				"managers = [\n" +
+				"  \"apt\", # official\n" +
+				"  \"flatpak\",\n" +
+				"]\n",
+			wantEntries: []ConfigEntry{
+				{Key: "official-only", Values: []string{"true"}, Line: 2},
+				{Key: "proxy", Values: []string{"http://proxy#1:3128"}, Line: 3},
+				{Table: "host.build-01", Key: "managers", Values: []string{"apt", "flatpak"}, IsList: true, Line: 6},
+			},
+		},
+		{name: "missing value", data: "offline\n", wantErr: "line 1: expected key = value"},
+		{name: "array of tables", data: "[[profile]]\n", wantErr: "line 1: invalid table"},
+		{name: "unquoted string", data: "\nreboot = never\n", wantErr: "line 2: reboot: invalid value [never]"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries, err := ParseConfig(test.data)
+			switch {
+			case test.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("error = %v, want %q", err, test.wantErr)
+				}
+			case err != nil:
+				t.Errorf("unexpected error %v", err)
+			case !reflect.DeepEqual(entries, test.wantEntries):
+				t.Errorf("entries = %+v, want %+v", entries, test.wantEntries)
+			}
+		})
+	}
+}
+
+func TestLoadConfigAndValidateSettings(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         string
+		wantLoadErr  string
+		wantProblems []string
+	}{
+		{name: "valid", data: "escalate = \"sudo\"\ndisk-policy = \"warn\"\nmin-battery = 50\nmax-bandwidth = \"2M\"\n"},
+		{name: "unknown key", data: "escalation = \"sudo\"\n", wantLoadErr: "did you mean"},
+		{name: "wrong type", data: "min-battery = \"lots\"\n", wantLoadErr: "min-battery"},
+		{name: "list for a single value", data: "reboot = [\"never\"]\n", wantLoadErr: "expected a single value"},
+		{
+			name: "invalid values",
+			data: "escalate = \"foo\"\ndisk-policy = \"nope\"\nreboot = \"sometimes\"\nmin-battery = 150\nmin-free-space = \"lots\"\n",
+			wantProblems: []string{
+				"escalation method [foo]",
+				"reboot policy [sometimes]",
+				"disk space policy [nope]",
+				"disk space threshold",
+				"battery settings",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Initialise variables
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(path, []byte(test.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			defer ResetFlags()
+
+			config, err := LoadConfig(path, "")
+			if err == nil {
+				err = config.Apply()
+			}
+			if test.wantLoadErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantLoadErr) {
+					t.Errorf("load error = %v, want %q", err, test.wantLoadErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected load error %v", err)
+			}
+
+			problems := ValidateSettings(config)
+			if len(problems) != len(test.wantProblems) {
+				t.Fatalf("problems = %v, want %d", problems, len(test.wantProblems))
+			}
+			for i, problem := range problems {
+				if !strings.HasPrefix(problem.Error(), path+": ") || !strings.Contains(problem.Error(), test.wantProblems[i]) {
+					t.Errorf("problem = %q, want %q from %s", problem, test.wantProblems[i], path)
+				}
+			}
+		})
+	}
+}
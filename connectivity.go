@@ -0,0 +1,347 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Connectivity checks: DNS resolution, TCP connect, HTTPS and captive portals
+
+package main
+
+// Import packages
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// // Default target, always tested
+const DEFAULT_NETWORK_TARGET string = "raw.githubusercontent.com"
+
+// // URL expected to answer with an empty 204 response when no captive portal is present
+const CAPTIVE_PORTAL_URL string = "http://connectivitycheck.gstatic.com/generate_204"
+
+// // Maximum number of targets checked at the same time
+const MAX_CONCURRENT_CHECKS int = 16
+
+// // Possible verdicts of a connectivity check
+const VERDICT_OK string = "OK"
+const VERDICT_BAD_TARGET string = "INVALID TARGET"
+const VERDICT_DNS_FAILED string = "DNS FAILED"
+const VERDICT_TCP_FAILED string = "TCP FAILED"
+const VERDICT_HTTPS_FAILED string = "HTTPS FAILED"
+const VERDICT_CAPTIVE_PORTAL string = "CAPTIVE PORTAL"
+
+// // Connectivity settings, set by flags
+var networkTimeout time.Duration = 10 * time.Second
+var networkTargets []string
+var captivePortalURL string = CAPTIVE_PORTAL_URL
+
+// Flag type that may be repeated, collecting every value given
+type StringListFlag struct {
+	Values *[]string
+}
+
+// Method to print the collected values
+func (list StringListFlag) String() string {
+	if list.Values == nil {
+		return ""
+	}
+	return strings.Join(*list.Values, ",")
+}
+
+// Method to collect another value
+func (list StringListFlag) Set(value string) error {
+	*list.Values = append(*list.Values, value)
+	return nil
+}
+
+// Settings and tools used to check connectivity
+//
+// Resolver and TLSConfig may be replaced, e.g. to test against a local httptest server.
+type ConnectivityChecker struct {
+	Timeout          time.Duration
+	Resolver         *net.Resolver
+	TLSConfig        *tls.Config
+	Proxy            func(*http.Request) (*url.URL, error)
+	CaptivePortalURL string
+}
+
+// Result of checking a single target
+type TargetResult struct {
+	Target        string
+	Proxy         string
+	Addresses     []string
+	ParseErr      error
+	DNSErr        error
+	TCPErr        error
+	HTTPSErr      error
+	StatusCode    int
+	CaptivePortal bool
+	Duration      time.Duration
+}
+
+// Method to create a ConnectivityChecker from the current settings
+func NewConnectivityChecker() *ConnectivityChecker {
+	return &ConnectivityChecker{
+		Timeout:          networkTimeout,
+		Resolver:         net.DefaultResolver,
+		Proxy:            ProxyFunc(),
+		CaptivePortalURL: captivePortalURL,
+	}
+}
+
+// Method to turn a target ("host", "host:port" or a full URL) into a URL
+func ParseNetworkTarget(target string) (*url.URL, error) {
+	if !strings.Contains(target, "://") {
+		target = "https://" + target
+	}
+	parsedURL, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if parsedURL.Hostname() == "" {
+		return nil, errors.New("missing host in target [" + target + "]")
+	}
+	return parsedURL, nil
+}
+
+// Method to find the port of a target URL, using the scheme's default if missing
+func TargetPort(targetURL *url.URL) string {
+	switch {
+	case targetURL.Port() != "":
+		return targetURL.Port()
+	case targetURL.Scheme == "http":
+		return "80"
+	default:
+		return "443"
+	}
+}
+
+// Method to create an HTTP client bound to the checker's settings
+func (checker *ConnectivityChecker) HTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Each transport gets its own copy, as net/http changes it while checks run concurrently
+	transport.TLSClientConfig = checker.TLSConfig.Clone()
+	transport.Proxy = checker.Proxy
+	transport.DialContext = (&net.Dialer{Timeout: checker.Timeout, Resolver: checker.Resolver}).DialContext
+	return &http.Client{Timeout: checker.Timeout, Transport: transport}
+}
+
+// Method to check DNS resolution, TCP connect and HTTPS of a single target
+func (checker *ConnectivityChecker) CheckTarget(ctx context.Context, target string) (result TargetResult) {
+	// Initialise variables
+	result = TargetResult{Target: target}
+	begin := time.Now()
+	defer func() { result.Duration = time.Since(begin) }()
+	ctx, cancel := context.WithTimeout(ctx, checker.Timeout)
+	defer cancel()
+
+	targetURL, err := ParseNetworkTarget(target)
+	if err != nil {
+		result.ParseErr = err
+		return result
+	}
+	host := targetURL.Hostname()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL.String(), nil)
+	if err != nil {
+		result.ParseErr = err
+		return result
+	}
+
+	// Behind a proxy, only the proxy itself is resolved and connected to
+	dialHost, dialPort := host, TargetPort(targetURL)
+	if checker.Proxy != nil {
+		proxy, err := checker.Proxy(request)
+		if err != nil {
+			result.ParseErr = err
+			return result
+		}
+		if proxy != nil {
+			result.Proxy = RedactSecrets(proxy.String())
+			dialHost, dialPort = proxy.Hostname(), TargetPort(proxy)
+		}
+	}
+
+	// DNS resolution (skipped for IP addresses)
+	switch net.ParseIP(dialHost) {
+	case nil:
+		result.Addresses, result.DNSErr = checker.Resolver.LookupHost(ctx, dialHost)
+		if result.DNSErr != nil {
+			return result
+		}
+	default:
+		result.Addresses = []string{dialHost}
+	}
+
+	// TCP connect, trying every resolved address
+	dialer := net.Dialer{Timeout: checker.Timeout}
+	for _, address := range result.Addresses {
+		var connection net.Conn
+		connection, result.TCPErr = dialer.DialContext(ctx, "tcp", net.JoinHostPort(address, dialPort))
+		if result.TCPErr == nil {
+			connection.Close()
+			break
+		}
+	}
+	if result.TCPErr != nil {
+		return result
+	}
+
+	// HTTPS request, any response from the target itself counts as reachable
+	//
+	// Redirects (e.g. to www. or a mirror) are not followed, captive portals are left to DetectCaptivePortal.
+	client := checker.HTTPClient()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	response, err := client.Do(request)
+	if err != nil {
+		result.HTTPSErr = err
+		return result
+	}
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+	response.Body.Close()
+	result.StatusCode = response.StatusCode
+	return result
+}
+
+// Method to check if a captive portal intercepts plain HTTP traffic
+func (checker *ConnectivityChecker) DetectCaptivePortal(ctx context.Context) (bool, error) {
+	// An empty URL disables captive portal detection
+	if checker.CaptivePortalURL == "" {
+		return false, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, checker.Timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, checker.CaptivePortalURL, nil)
+	if err != nil {
+		return false, err
+	}
+	client := checker.HTTPClient()
+	// Portals usually answer with a redirect to their login page, so do not follow it
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	response, err := client.Do(request)
+	if err != nil {
+		return false, err
+	}
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+	response.Body.Close()
+	return response.StatusCode != http.StatusNoContent || len(body) > 0, nil
+}
+
+// Method to check all targets concurrently, along with captive portal detection
+func (checker *ConnectivityChecker) CheckTargets(ctx context.Context, targets []string) []TargetResult {
+	// Initialise variables
+	var waitGroup sync.WaitGroup
+	var captivePortal bool
+	results := make([]TargetResult, len(targets))
+
+	limit := make(chan struct{}, MAX_CONCURRENT_CHECKS)
+
+	// Each target writes only to its own index
+	for i, target := range targets {
+		waitGroup.Add(1)
+		go func(i int, target string) {
+			defer waitGroup.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+			results[i] = checker.CheckTarget(ctx, target)
+		}(i, target)
+	}
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		captivePortal, _ = checker.DetectCaptivePortal(ctx)
+	}()
+	waitGroup.Wait()
+
+	// Blame failed HTTPS checks on the captive portal, if one is present
+	for i := range results {
+		results[i].CaptivePortal = captivePortal && results[i].HTTPSErr != nil
+		logger.Info("connectivity check", "target", results[i].Target, "proxy", results[i].Proxy, "addresses", results[i].Addresses, "verdict", results[i].Verdict(), "duration", results[i].Duration, "err", results[i].Err())
+	}
+	return results
+}
+
+// Method to give a verdict on a checked target
+func (result TargetResult) Verdict() string {
+	switch {
+	case result.ParseErr != nil:
+		return VERDICT_BAD_TARGET
+	case result.DNSErr != nil:
+		return VERDICT_DNS_FAILED
+	case result.TCPErr != nil:
+		return VERDICT_TCP_FAILED
+	case result.CaptivePortal:
+		return VERDICT_CAPTIVE_PORTAL
+	case result.HTTPSErr != nil:
+		return VERDICT_HTTPS_FAILED
+	default:
+		return VERDICT_OK
+	}
+}
+
+// Method to return the error of the failed stage, if any
+func (result TargetResult) Err() error {
+	switch {
+	case result.ParseErr != nil:
+		return result.ParseErr
+	case result.DNSErr != nil:
+		return result.DNSErr
+	case result.TCPErr != nil:
+		return result.TCPErr
+	default:
+		return result.HTTPSErr
+	}
+}
+
+// Method to print the result of a checked target
+func PrintTargetResult(result TargetResult) {
+	// Initialise variables
+	var dnsStatus string = "skipped"
+	var tcpStatus string = "skipped"
+	var httpsStatus string = "skipped"
+
+	switch {
+	case result.DNSErr != nil:
+		dnsStatus = "failed"
+	case result.Addresses != nil:
+		dnsStatus = strings.Join(result.Addresses, ",")
+	}
+	switch {
+	case result.TCPErr != nil:
+		tcpStatus = "failed"
+	case result.Addresses != nil && result.DNSErr == nil:
+		tcpStatus = "ok"
+	}
+	switch {
+	case result.HTTPSErr != nil:
+		httpsStatus = "failed"
+	case result.StatusCode != 0:
+		httpsStatus = fmt.Sprint(result.StatusCode)
+	}
+
+	// Details shown after the verdict
+	details := " (dns: " + dnsStatus + ", tcp: " + tcpStatus + ", https: " + httpsStatus + ", " + result.Duration.Round(time.Millisecond).String() + ")"
+	if result.Proxy != "" {
+		details = " (via proxy [" + result.Proxy + "])" + details
+	}
+
+	switch result.Verdict() {
+	case VERDICT_OK:
+		PrintSuccess("* Network test with domain [" + result.Target + "] successful!" + details)
+	default:
+		PrintFailure("!!Error when testing domain [" + result.Target + "]: " + result.Verdict() + details)
+	}
+	if err := result.Err(); err != nil {
+		PrintFailure("\t" + err.Error())
+	}
+	if result.CaptivePortal {
+		PrintWarning("\t* A captive portal seems to intercept traffic, log in through a browser first")
+	}
+}
@@ -0,0 +1,173 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Tests of the connectivity checks, against local httptest servers
+
+package main
+
+// Import packages
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Method to create a checker trusting a test server, with DNS always failing
+func NewTestChecker(server *httptest.Server, portalURL string) *ConnectivityChecker {
+	// Initialise variables
+	var tlsConfig *tls.Config
+	if server != nil {
+		tlsConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+	}
+
+	return &ConnectivityChecker{
+		Timeout: 2 * time.Second,
+		Resolver: &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network string, address string) (net.Conn, error) {
+				return nil, errors.New("no DNS in tests")
+			},
+		},
+		TLSConfig:        tlsConfig,
+		CaptivePortalURL: portalURL,
+	}
+}
+
+// Method to find a local address nothing listens on
+func ClosedAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	return address
+}
+
+func TestParseNetworkTarget(t *testing.T) {
+	tests := []struct {
+		target  string
+		wantURL string
+		wantErr bool
+	}{
+		{"example.com", "https://example.com", false},
+		{"example.com:8443", "https://example.com:8443", false},
+		{"http://example.com/path", "http://example.com/path", false},
+		{"https://", "", true},
+		{"http://[::1", "", true},
+	}
+	for _, test := range tests {
+		parsed, err := ParseNetworkTarget(test.target)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseNetworkTarget(%q) error = %v, want error %v", test.target, err, test.wantErr)
+			continue
+		}
+		if err == nil && parsed.String() != test.wantURL {
+			t.Errorf("ParseNetworkTarget(%q) = %q, want %q", test.target, parsed.String(), test.wantURL)
+		}
+	}
+}
+
+func TestCheckTarget(t *testing.T) {
+	ok := httptest.NewTLSServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	}))
+	defer ok.Close()
+	notFound := httptest.NewTLSServer(http.NotFoundHandler())
+	defer notFound.Close()
+	// e.g. google.com redirecting to www.google.com, or a mirror redirector
+	redirect := httptest.NewTLSServer(http.RedirectHandler("https://mirror.invalid/", http.StatusFound))
+	defer redirect.Close()
+	plain := httptest.NewServer(http.NotFoundHandler())
+	defer plain.Close()
+
+	tests := []struct {
+		name        string
+		server      *httptest.Server
+		target      string
+		wantVerdict string
+		wantStatus  int
+	}{
+		{"reachable", ok, ok.URL, VERDICT_OK, http.StatusOK},
+		{"error status still reachable", notFound, strings.TrimPrefix(notFound.URL, "https://"), VERDICT_OK, http.StatusNotFound},
+		{"redirect to another host", redirect, redirect.URL, VERDICT_OK, http.StatusFound},
+		{"plain HTTP over TLS", plain, "https://" + strings.TrimPrefix(plain.URL, "http://"), VERDICT_HTTPS_FAILED, 0},
+		{"untrusted certificate", nil, ok.URL, VERDICT_HTTPS_FAILED, 0},
+		{"nothing listening", nil, "https://" + ClosedAddress(t), VERDICT_TCP_FAILED, 0},
+		{"unresolvable host", nil, "unresolvable.invalid", VERDICT_DNS_FAILED, 0},
+		{"invalid target", nil, "https://", VERDICT_BAD_TARGET, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := NewTestChecker(test.server, "").CheckTarget(context.Background(), test.target)
+			if result.Verdict() != test.wantVerdict {
+				t.Errorf("verdict = %s (%v), want %s", result.Verdict(), result.Err(), test.wantVerdict)
+			}
+			if result.StatusCode != test.wantStatus {
+				t.Errorf("status = %d, want %d", result.StatusCode, test.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCheckTargetsCaptivePortal(t *testing.T) {
+	noPortal := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusNoContent)
+	}))
+	defer noPortal.Close()
+	portal := httptest.NewServer(http.RedirectHandler("http://login.invalid/", http.StatusFound))
+	defer portal.Close()
+	target := httptest.NewTLSServer(http.NotFoundHandler())
+	defer target.Close()
+
+	tests := []struct {
+		name        string
+		portalURL   string
+		trusted     bool
+		wantVerdict string
+	}{
+		{"no portal, reachable", noPortal.URL, true, VERDICT_OK},
+		{"no portal, HTTPS failed", noPortal.URL, false, VERDICT_HTTPS_FAILED},
+		{"portal, HTTPS failed", portal.URL, false, VERDICT_CAPTIVE_PORTAL},
+		{"portal, reachable anyway", portal.URL, true, VERDICT_OK},
+		{"detection disabled", "", false, VERDICT_HTTPS_FAILED},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checker := NewTestChecker(target, test.portalURL)
+			if !test.trusted {
+				checker.TLSConfig = nil
+			}
+			results := checker.CheckTargets(context.Background(), []string{target.URL})
+			if len(results) != 1 || results[0].Verdict() != test.wantVerdict {
+				t.Errorf("verdicts = %v, want [%s]", results, test.wantVerdict)
+			}
+		})
+	}
+}
+
+func TestCheckTargetTimeout(t *testing.T) {
+	// Initialise variables
+	release := make(chan struct{})
+	slow := httptest.NewTLSServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	checker := NewTestChecker(slow, "")
+	checker.Timeout = 200 * time.Millisecond
+	result := checker.CheckTarget(context.Background(), slow.URL)
+	if result.Verdict() != VERDICT_HTTPS_FAILED {
+		t.Errorf("verdict = %s, want %s", result.Verdict(), VERDICT_HTTPS_FAILED)
+	}
+	if result.Duration > 2*time.Second {
+		t.Errorf("took %s, timeout not applied", result.Duration)
+	}
+}
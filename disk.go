@@ -0,0 +1,446 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Disk space of the filesystems package managers write to, checked before upgrading
+
+package main
+
+// Import packages
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// // Disk space policies, when a filesystem is short of space
+const DISK_POLICY_ABORT string = "abort" // Skip the package manager
+const DISK_POLICY_CLEAN string = "clean" // Clean its caches, then skip it if still short
+const DISK_POLICY_WARN string = "warn"   // Update anyway
+
+// // Disk space settings, set by flags
+var minFreeSpaceSetting string = "500M"
+var minFreeBootSetting string = "100M"
+var minFreeInodes int = 5
+var diskPolicy string = DISK_POLICY_ABORT
+
+// // Disk space thresholds in bytes, parsed from the settings
+var minFreeSpace int64
+var minFreeBoot int64
+
+// // Download sizes printed by package managers, e.g. "Total download size: 12 M"
+var DOWNLOAD_SIZE_PATTERNS []*regexp.Regexp = []*regexp.Regexp{
+	regexp.MustCompile(`Total download size: ([0-9.]+) ([kKMG]?i?B?)`),
+	regexp.MustCompile(`Need to download ([0-9.]+) ([kKMG]?i?B)`),
+	regexp.MustCompile(`Overall download size: ([0-9.]+) ([kKMG]?i?B)`),
+}
+
+// Free space and inodes of the filesystem holding a path
+type DiskSpace struct {
+	Path        string
+	Device      uint64
+	Free        uint64
+	Total       uint64
+	FreeInodes  uint64
+	TotalInodes uint64
+}
+
+// Method to format a number of bytes, e.g. 1.5 GiB
+func FormatBytes(bytes uint64) string {
+	// Initialise variables
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	value := float64(bytes)
+	unit := 0
+
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
+
+// Method to parse a size such as "512K", "2M" or "1G" (in bytes)
+func ParseSize(value string) (int64, error) {
+	// Initialise variables
+	var multiplier int64 = 1
+	value = strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(value)), "B")
+	value = strings.TrimSuffix(value, "I")
+
+	switch {
+	case value == "" || value == "0":
+		return 0, nil
+	case strings.HasSuffix(value, "K"):
+		multiplier = 1024
+	case strings.HasSuffix(value, "M"):
+		multiplier = 1024 * 1024
+	case strings.HasSuffix(value, "G"):
+		multiplier = 1024 * 1024 * 1024
+	}
+	// Only a single unit is allowed
+	if multiplier != 1 {
+		value = value[:len(value)-1]
+	}
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || number < 0 {
+		return 0, errors.New("invalid size [" + value + "], expected e.g. 512K, 2M")
+	}
+	return int64(number * float64(multiplier)), nil
+}
+
+// Method to validate a disk space policy
+func ValidateDiskPolicy(policy string) bool {
+	switch policy {
+	case DISK_POLICY_ABORT, DISK_POLICY_CLEAN, DISK_POLICY_WARN:
+		return true
+	}
+	return false
+}
+
+// Method to list the disk space policies, for shell completion
+func DiskPolicyChoices(config *Config) []string {
+	return []string{DISK_POLICY_ABORT, DISK_POLICY_CLEAN, DISK_POLICY_WARN}
+}
+
+// Method to find the directories a package manager writes to, its download cache first
+func DiskPaths(pkgNum int, official bool, scope PrivilegeScope) []string {
+	switch official {
+	// Official package managers
+	case true:
+		switch pkgNum {
+		// Apt package manager
+		case 0:
+			return []string{"/var/cache/apt/archives", "/var/lib/dpkg", "/boot", "/usr"}
+		// Dnf package manager
+		case 1:
+			return []string{"/var/cache/dnf", "/var/lib/rpm", "/boot", "/usr"}
+		// Transactional-update package manager (new snapshots)
+		case 2:
+			return []string{"/var/cache/zypp", "/.snapshots", "/boot"}
+		// Zypper package manager
+		case 3:
+			return []string{"/var/cache/zypp", "/var/lib/rpm", "/boot", "/usr"}
+		// Yum package manager
+		case 4:
+			return []string{"/var/cache/yum", "/var/lib/rpm", "/boot", "/usr"}
+		// Rpm-ostree package manager
+		case 5:
+			return []string{"/ostree/repo", "/boot"}
+		// Apk package manager
+		case 6:
+			return []string{"/var/cache/apk", "/lib/apk", "/boot", "/usr"}
+		// Swupd package manager
+		case 7:
+			return []string{"/var/lib/swupd", "/boot", "/usr"}
+		// Pacman package manager
+		case 8:
+			return []string{"/var/cache/pacman/pkg", "/var/lib/pacman", "/boot", "/usr"}
+		// Pkg_add package manager
+		case 9:
+			return []string{"/var/db/pkg", "/usr/local"}
+		// Pkg package manager
+		case 10:
+			return []string{"/var/cache/pkg", "/var/db/pkg", "/usr/local"}
+		// Eopkg package manager
+		case 11:
+			return []string{"/var/cache/eopkg", "/var/lib/eopkg", "/boot", "/usr"}
+		// Slackpkg package manager
+		case 12:
+			return []string{"/var/cache/packages", "/var/lib/slackpkg", "/boot", "/usr"}
+		// Xbps package manager
+		case 13:
+			return []string{"/var/cache/xbps", "/var/db/xbps", "/boot", "/usr"}
+		}
+	// Alternative package managers
+	case false:
+		// Initialise variables
+		home := os.Getenv("HOME")
+		if scope.Account != nil {
+			home = scope.Account.HomeDir
+		}
+		switch pkgNum {
+		// Brew package manager
+		case 0:
+			return []string{filepath.Join(home, ".cache", "Homebrew"), "/home/linuxbrew/.linuxbrew", "/opt/homebrew", "/usr/local"}
+		// Snap package manager
+		case 1:
+			return []string{"/var/lib/snapd"}
+		// Flatpak package manager
+		case 3:
+			if scope.Elevated {
+				return []string{"/var/lib/flatpak"}
+			}
+			return []string{filepath.Join(home, ".local", "share", "flatpak")}
+		}
+	}
+	return nil
+}
+
+// Method to find the arguments cleaning the download cache of a package manager
+func CleanCacheArgs(pkgNum int, official bool) []string {
+	switch official {
+	// Official package managers
+	case true:
+		switch pkgNum {
+		// Apt package manager
+		case 0:
+			return []string{"clean"}
+		// Dnf & Yum package managers
+		case 1, 4:
+			return []string{"clean", "packages"}
+		// Zypper package manager
+		case 3:
+			return []string{"clean"}
+		// Apk package manager
+		case 6:
+			return []string{"cache", "clean"}
+		// Pacman package manager
+		case 8:
+			return []string{"-Sc", "--noconfirm"}
+		// Pkg package manager
+		case 10:
+			return []string{"clean", "-y"}
+		// Eopkg package manager
+		case 11:
+			return []string{"delete-cache"}
+		}
+	// Alternative package managers
+	case false:
+		switch pkgNum {
+		// Brew package manager
+		case 0:
+			return []string{"cleanup"}
+		}
+	}
+	return nil
+}
+
+// Method to find the arguments printing what an upgrade would download, if possible
+func DownloadSizeArgs(pkgNum int, official bool) []string {
+	switch official {
+	// Official package managers
+	case true:
+		switch pkgNum {
+		// Apt package manager
+		case 0:
+			return []string{"-qq", "--print-uris", "-o", "Debug::NoLocking=1", "dist-upgrade"}
+		// Dnf & Yum package managers
+		case 1, 4:
+			return []string{"-C", "upgrade", "--assumeno"}
+		// Zypper package manager
+		case 3:
+			return []string{"--non-interactive", "--no-refresh", "update", "--dry-run"}
+		// Pacman package manager
+		case 8:
+			return []string{"-Sup", "--print-format", "%s"}
+		}
+	}
+	return nil
+}
+
+// Method to add up the download size printed by a package manager
+func ParseDownloadSize(pkgNum int, official bool, output string) (int64, bool) {
+	// Initialise variables
+	var total int64
+	var found bool
+
+	switch {
+	// Apt package manager: 'URI' file size hash
+	case official && pkgNum == 0:
+		for _, line := range strings.Split(output, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 3 || !strings.HasPrefix(fields[0], "'") {
+				continue
+			}
+			if size, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+				total, found = total+size, true
+			}
+		}
+		return total, found || strings.TrimSpace(output) == ""
+	// Pacman package manager: one size per line
+	case official && pkgNum == 8:
+		for _, line := range strings.Split(output, "\n") {
+			if size, err := strconv.ParseInt(strings.TrimSpace(line), 10, 64); err == nil {
+				total, found = total+size, true
+			}
+		}
+		return total, found || strings.TrimSpace(output) == ""
+	}
+	// Others print a summary line
+	for _, pattern := range DOWNLOAD_SIZE_PATTERNS {
+		if match := pattern.FindStringSubmatch(output); match != nil {
+			size, err := ParseSize(match[1] + match[2])
+			return size, err == nil
+		}
+	}
+	return 0, false
+}
+
+// Method to estimate what an upgrade would download, if the package manager can tell
+//
+// The estimate is taken before the package manager refreshes its indexes, so it comes
+// from the cached ones and misses updates published since the last refresh. Proxy and
+// bandwidth settings are given as for other steps, as some dry runs still use the network.
+func EstimateDownloadSize(manager ScopedManager, pkgNum int, official bool, label string, manual bool, extraArgs []string, env []string) (int64, bool) {
+	args := DownloadSizeArgs(pkgNum, official)
+	if args == nil || offlineFlag {
+		return 0, false
+	}
+	args = append(append(args, manager.Scope.Args...), extraArgs...)
+	// Let the USER run, skip or edit the dry run, or abort, if manual
+	if manual {
+		decision, editedArgs := ConfirmStep(label, manager.Path, manager.Escalation, args)
+		switch decision {
+		case DECISION_SKIP:
+			return 0, false
+		case DECISION_ABORT:
+			RequestCancel()
+			return 0, false
+		}
+		args = editedArgs
+	}
+	name, commandArgs, options := manager.Command(args, env, false)
+	options.Timeout = stepTimeout
+	// Dry runs may exit with an error (e.g. dnf --assumeno), so only the output counts
+	stdout, _, err := RunCommand(options, name, commandArgs...)
+	size, found := ParseDownloadSize(pkgNum, official, string(stdout))
+	logger.Debug("download size estimated", "manager", manager.Path, "size", size, "found", found, "err", err)
+	return size, found
+}
+
+// Method to find the nearest existing directory of a path
+func ExistingAncestor(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// Method to list the filesystems short of space or inodes for a package manager
+//
+// The download cache also needs room for the estimated download.
+func DiskShortages(paths []string, download int64) []string {
+	// Initialise variables
+	var shortages []string
+	needed := map[uint64]int64{}
+	spaces := map[uint64]DiskSpace{}
+	var devices []uint64
+
+	for i, path := range paths {
+		// Only check /boot when it exists
+		if path == "/boot" {
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+		}
+		space, err := DiskUsage(ExistingAncestor(path))
+		if err != nil {
+			logger.Debug("disk usage unavailable", "path", path, "err", err)
+			continue
+		}
+		space.Path = path
+		// Filesystems shared by several paths need the largest threshold, and the download once
+		if _, seen := spaces[space.Device]; !seen {
+			spaces[space.Device] = space
+			devices = append(devices, space.Device)
+		}
+		threshold := minFreeSpace
+		if path == "/boot" {
+			threshold = minFreeBoot
+		}
+		needed[space.Device] = max(needed[space.Device], threshold)
+		if i == 0 {
+			needed[space.Device] += download
+		}
+	}
+
+	for _, device := range devices {
+		space := spaces[device]
+		if int64(space.Free) < needed[device] {
+			shortages = append(shortages, space.Path+": "+FormatBytes(space.Free)+" free, "+FormatBytes(uint64(needed[device]))+" needed")
+		}
+		if space.TotalInodes > 0 && space.FreeInodes*100/space.TotalInodes < uint64(minFreeInodes) {
+			shortages = append(shortages, space.Path+": "+strconv.FormatUint(space.FreeInodes*100/space.TotalInodes, 10)+"% inodes free, "+strconv.Itoa(minFreeInodes)+"% needed")
+		}
+	}
+	return shortages
+}
+
+// Method to check free disk space before a package manager changes anything, following the policy
+//
+// Returns an error if the package manager should be skipped. In manual mode, the dry run
+// estimating the download and the cleaning of caches are confirmed like other steps.
+func DiskPreflight(manager ScopedManager, pkgNum int, official bool, label string, manual bool, extraArgs []string, env []string) error {
+	// Initialise variables
+	paths := DiskPaths(pkgNum, official, manager.Scope)
+	if len(paths) == 0 {
+		return nil
+	}
+
+	download, estimated := EstimateDownloadSize(manager, pkgNum, official, label, manual, extraArgs, env)
+	if IsCancelled() {
+		return errors.New("aborted by USER")
+	}
+	if estimated && download > 0 {
+		PrintVerbose("\t* [" + label + "] will download about " + FormatBytes(uint64(download)) + " (from cached indexes)")
+	}
+	shortages := DiskShortages(paths, download)
+	if len(shortages) == 0 {
+		return nil
+	}
+	logger.Warn("disk space low", "manager", label, "shortages", shortages, "download", download, "policy", diskPolicy)
+	PrintWarning("!!Low disk space for [" + label + "]: " + strings.Join(shortages, "; "))
+
+	switch diskPolicy {
+	case DISK_POLICY_WARN:
+		runReport.AddNote("Low disk space for [" + label + "], updated anyway (--disk-policy=warn): " + strings.Join(shortages, "; "))
+		return nil
+	case DISK_POLICY_CLEAN:
+		args := CleanCacheArgs(pkgNum, official)
+		if args == nil {
+			break
+		}
+		args = append(args, manager.Scope.Args...)
+		// Let the USER run, skip or edit the cleaning, or abort, if manual
+		if manual {
+			decision, editedArgs := ConfirmStep(label, manager.Path, manager.Escalation, args)
+			switch decision {
+			case DECISION_SKIP:
+				runReport.AddStep(label, append([]string{manager.Path}, args...), STEP_SKIPPED, errors.New("skipped by USER"), 0)
+				return errors.New("not enough disk space (" + strings.Join(shortages, "; ") + ")")
+			case DECISION_ABORT:
+				RequestCancel()
+				return errors.New("aborted by USER")
+			}
+			args = editedArgs
+		}
+		PrintStatus("* Cleaning caches of [" + label + "] to free space")
+		name, commandArgs, options := manager.Command(args, nil, false)
+		options.Timeout = stepTimeout
+		cleanBegin := time.Now()
+		_, _, err := RunCommand(options, name, commandArgs...)
+		switch err {
+		case nil:
+			runReport.AddStep(label, append([]string{manager.Path}, args...), STEP_OK, nil, time.Since(cleanBegin))
+		default:
+			PrintFailure("!!Could NOT clean caches of ["+label+"]:", err)
+			runReport.AddStep(label, append([]string{manager.Path}, args...), STEP_FAILED, err, time.Since(cleanBegin))
+		}
+		if shortages = DiskShortages(paths, download); len(shortages) == 0 {
+			return nil
+		}
+	}
+	return errors.New("not enough disk space (" + strings.Join(shortages, "; ") + ")")
+}
@@ -0,0 +1,35 @@
+//go:build openbsd
+
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Free disk space and inodes, through statfs (OpenBSD names its fields differently)
+
+package main
+
+// Import packages
+import (
+	"os"
+	"syscall"
+)
+
+// Method to find the free space and inodes of the filesystem holding a path
+func DiskUsage(path string) (DiskSpace, error) {
+	// Initialise variables
+	var stat syscall.Statfs_t
+	space := DiskSpace{Path: path}
+
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return space, err
+	}
+	space.Free = uint64(stat.F_bavail) * uint64(stat.F_bsize)
+	space.Total = uint64(stat.F_blocks) * uint64(stat.F_bsize)
+	space.FreeInodes = uint64(stat.F_ffree)
+	space.TotalInodes = uint64(stat.F_files)
+	if info, err := os.Stat(path); err == nil {
+		if sys, ok := info.Sys().(*syscall.Stat_t); ok {
+			space.Device = uint64(sys.Dev)
+		}
+	}
+	return space, nil
+}
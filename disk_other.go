@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !freebsd && !openbsd
+
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Free disk space and inodes, on systems without statfs support
+
+package main
+
+// Import packages
+import (
+	"errors"
+)
+
+// Method to find the free space and inodes of the filesystem holding a path (unsupported here)
+func DiskUsage(path string) (DiskSpace, error) {
+	return DiskSpace{Path: path}, errors.New("disk usage is not supported on " + OS_TYPE)
+}
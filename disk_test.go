@@ -0,0 +1,90 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Tests of disk space thresholds, and of download sizes printed by package managers
+
+package main
+
+// Import packages
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"", 0, false},
+		{"1024", 1024, false},
+		{"512K", 512 * 1024, false},
+		{"500M", 500 * 1024 * 1024, false},
+		{"2G", 2 * 1024 * 1024 * 1024, false},
+		{"1.5GiB", 1536 * 1024 * 1024, false},
+		{"55.6 MiB", 58300825, false},
+		{"100kB", 100 * 1024, false},
+		{"2GM", 0, true},
+		{"-1M", 0, true},
+		{"lots", 0, true},
+	}
+	for _, test := range tests {
+		got, err := ParseSize(test.value)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d (error %v)", test.value, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestParseDownloadSize(t *testing.T) {
+	tests := []struct {
+		name      string
+		pkgNum    int
+		official  bool
+		output    string
+		want      int64
+		wantFound bool
+	}{
+		{"apt", 0, true, "'http://deb.debian.org/debian/pool/main/c/curl/curl_8.deb' curl_8.deb 315024 SHA256:ab\n'http://deb.debian.org/debian/pool/main/t/tzdata/tzdata.deb' tzdata.deb 260000 SHA256:cd\n", 575024, true},
+		{"apt up to date", 0, true, "", 0, true},
+		{"apt unexpected output", 0, true, "E: Unable to locate package\n", 0, false},
+		{"dnf", 1, true, "Dependencies resolved.\nTotal download size: 12 M\nOperation aborted.\n", 12 * 1024 * 1024, true},
+		{"yum in kilobytes", 4, true, "Total download size: 850 k\nExiting on user command\n", 850 * 1024, true},
+		{"zypper", 3, true, "The following 3 packages are going to be upgraded:\n  a b c\nOverall download size: 55.6 MiB. Already cached: 0 B.\n", 58300825, true},
+		{"zypper up to date", 3, true, "Nothing to do.\n", 0, false},
+		{"pacman", 8, true, "1048576\n2048\n", 1050624, true},
+		{"pacman up to date", 8, true, " \n", 0, true},
+	}
+	for _, test := range tests {
+		got, found := ParseDownloadSize(test.pkgNum, test.official, test.output)
+		if got != test.want || found != test.wantFound {
+			t.Errorf("%s: ParseDownloadSize() = %d, %v, want %d, %v", test.name, got, found, test.want, test.wantFound)
+		}
+	}
+}
+
+func TestEstimateDownloadSizeUsesProxy(t *testing.T) {
+	// Initialise variables
+	dir := t.TempDir()
+	// Fake apt-get, only reaching its repository through the proxy given
+	script := "#!/bin/sh\n" +
+		"[ \"$https_proxy\" = http://proxy.example.com:3128 ] || exit 100\n" +
+		"case \"$*\" in *\"-o Acquire::http::Dl-Limit=1024\"*) ;; *) exit 100;; esac\n" +
+		"echo \"'http://deb.debian.org/debian/pool/main/c/curl/curl_8.deb' curl_8.deb 4096 SHA256:ab\"\n"
+	aptGet := filepath.Join(dir, "apt-get")
+	if err := os.WriteFile(aptGet, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	manager := ScopedManager{Path: aptGet}
+
+	if size, _ := EstimateDownloadSize(manager, 0, true, "apt", false, nil, nil); size != 0 {
+		t.Errorf("estimated %d without the proxy", size)
+	}
+	size, found := EstimateDownloadSize(manager, 0, true, "apt", false, []string{"-o", "Acquire::http::Dl-Limit=1024"}, []string{"https_proxy=http://proxy.example.com:3128"})
+	if !found || size != 4096 {
+		t.Errorf("EstimateDownloadSize() = %d, %v, want 4096, true", size, found)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build linux || darwin || freebsd
+
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Free disk space and inodes, through statfs
+
+package main
+
+// Import packages
+import (
+	"os"
+	"syscall"
+)
+
+// Method to find the free space and inodes of the filesystem holding a path
+func DiskUsage(path string) (DiskSpace, error) {
+	// Initialise variables
+	var stat syscall.Statfs_t
+	space := DiskSpace{Path: path}
+
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return space, err
+	}
+	space.Free = uint64(stat.Bavail) * uint64(stat.Bsize)
+	space.Total = uint64(stat.Blocks) * uint64(stat.Bsize)
+	space.FreeInodes = uint64(stat.Ffree)
+	space.TotalInodes = uint64(stat.Files)
+	if info, err := os.Stat(path); err == nil {
+		if sys, ok := info.Sys().(*syscall.Stat_t); ok {
+			space.Device = uint64(sys.Dev)
+		}
+	}
+	return space, nil
+}
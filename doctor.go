@@ -0,0 +1,568 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Doctor command: read-only checks of what usually blocks updates
+
+package main
+
+// Import packages
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// // Severities of doctor findings, from best to worst
+const SEVERITY_OK int = 0
+const SEVERITY_INFO int = 1
+const SEVERITY_WARNING int = 2
+const SEVERITY_CRITICAL int = 3
+
+// // Exit codes of the doctor command, from its worst finding
+const DOCTOR_EXIT_WARNINGS int = 1
+const DOCTOR_EXIT_CRITICAL int = 2
+
+// // Kinds of package manager locks
+const LOCK_FCNTL int = 0   // Locked through fcntl while in use
+const LOCK_EXISTS int = 1  // Exists only while in use
+const LOCK_PIDFILE int = 2 // Holds the process ID of the user
+
+// // Filesystems checked for free space and inodes
+var DOCTOR_DISK_PATHS []string = []string{"/", "/var", "/boot", "/usr"}
+
+// // Free space below which a filesystem is critical (warnings follow --min-free-space and --min-free-boot)
+const DOCTOR_DISK_CRITICAL uint64 = 256 << 20
+const DOCTOR_BOOT_CRITICAL uint64 = 50 << 20
+
+// // Percentage of free inodes below which a filesystem is critical (warnings follow --min-free-inodes)
+const DOCTOR_INODES_CRITICAL uint64 = 1
+
+// // Keyrings trusted by apt, checked for expired keys
+var DOCTOR_APT_KEYRINGS []string = []string{"/etc/apt/trusted.gpg", "/etc/apt/trusted.gpg.d", "/etc/apt/keyrings"}
+
+// // Clock skew from the network target's Date header considered a warning, then critical
+const DOCTOR_SKEW_WARN time.Duration = time.Minute
+const DOCTOR_SKEW_CRITICAL time.Duration = time.Hour
+
+// A single finding of the doctor command, with what to do about it
+type Finding struct {
+	Check    string
+	Severity int
+	Detail   string
+	Fix      string
+}
+
+// A read-only command checking the health of a package manager
+type DoctorCheck struct {
+	Command []string
+	// Severity when the command fails, or prints anything with FailOnOutput
+	Severity     int
+	FailOnOutput bool
+	Fix          string
+}
+
+// A lock file of a package manager
+type PkgLock struct {
+	Path string
+	Kind int
+}
+
+// Method to find the read-only checks of a package manager
+func DoctorChecks(pkgNum int, official bool) []DoctorCheck {
+	// Initialise variables
+	rpmCheck := DoctorCheck{Command: []string{"rpm", "--verifydb"}, Severity: SEVERITY_CRITICAL, Fix: "rebuild the rpm database: sudo rpm --rebuilddb"}
+
+	switch official {
+	// Official package managers
+	case true:
+		switch pkgNum {
+		// Apt package manager
+		case 0:
+			return []DoctorCheck{
+				{Command: []string{"dpkg", "--audit"}, Severity: SEVERITY_CRITICAL, FailOnOutput: true, Fix: "finish interrupted installs: sudo dpkg --configure -a"},
+				// Without the dpkg lock, which only ROOT may take
+				{Command: []string{"apt-get", "-o", "Debug::NoLocking=1", "check"}, Severity: SEVERITY_CRITICAL, Fix: "fix broken dependencies: sudo apt-get -f install"},
+			}
+		// Dnf package manager
+		case 1:
+			return []DoctorCheck{
+				{Command: []string{"dnf", "-q", "-C", "check"}, Severity: SEVERITY_WARNING, Fix: "remove duplicate or broken packages listed, e.g. sudo dnf remove --duplicates"},
+				rpmCheck,
+			}
+		// Yum package manager
+		case 4:
+			return []DoctorCheck{
+				{Command: []string{"yum", "-q", "-C", "check"}, Severity: SEVERITY_WARNING, Fix: "remove duplicate or broken packages listed, e.g. sudo package-cleanup --cleandupes"},
+				rpmCheck,
+			}
+		// Zypper package manager
+		case 3:
+			return []DoctorCheck{rpmCheck}
+		// Pacman package manager
+		case 8:
+			return []DoctorCheck{
+				{Command: []string{"pacman", "-Dk"}, Severity: SEVERITY_CRITICAL, Fix: "reinstall or remove the packages with missing dependencies listed"},
+			}
+		// Pkg package manager
+		case 10:
+			return []DoctorCheck{
+				{Command: []string{"pkg", "check", "-d", "-n"}, Severity: SEVERITY_WARNING, FailOnOutput: true, Fix: "install missing dependencies: sudo pkg check -d -y"},
+			}
+		}
+	}
+	return nil
+}
+
+// Method to find the lock files of a package manager
+func DoctorLocks(pkgNum int, official bool) []PkgLock {
+	// Initialise variables
+	rpmLocks := []PkgLock{{Path: "/var/lib/rpm/.rpm.lock", Kind: LOCK_FCNTL}, {Path: "/usr/lib/sysimage/rpm/.rpm.lock", Kind: LOCK_FCNTL}}
+
+	switch official {
+	// Official package managers
+	case true:
+		switch pkgNum {
+		// Apt package manager
+		case 0:
+			return []PkgLock{
+				{Path: "/var/lib/dpkg/lock-frontend", Kind: LOCK_FCNTL},
+				{Path: "/var/lib/dpkg/lock", Kind: LOCK_FCNTL},
+				{Path: "/var/lib/apt/lists/lock", Kind: LOCK_FCNTL},
+				{Path: "/var/cache/apt/archives/lock", Kind: LOCK_FCNTL},
+			}
+		// Dnf & Yum package managers
+		case 1, 4:
+			return rpmLocks
+		// Zypper package manager
+		case 3:
+			return append([]PkgLock{{Path: "/run/zypp.pid", Kind: LOCK_PIDFILE}}, rpmLocks...)
+		// Pacman package manager
+		case 8:
+			return []PkgLock{{Path: "/var/lib/pacman/db.lck", Kind: LOCK_EXISTS}}
+		}
+	}
+	return nil
+}
+
+// Method to run the checks of a package manager
+func RunDoctorChecks(name string, checks []DoctorCheck) []Finding {
+	// Initialise variables
+	var findings []Finding
+
+	for _, check := range checks {
+		label := name + ": " + strings.Join(check.Command, " ")
+		path, err := ResolveManager(check.Command[0], false, nil)
+		if err != nil {
+			findings = append(findings, Finding{Check: label, Severity: SEVERITY_INFO, Detail: "skipped, " + check.Command[0] + " not found"})
+			continue
+		}
+		stdout, stderr, err := RunCommand(CommandOptions{Timeout: stepTimeout}, path, check.Command[1:]...)
+		output := strings.TrimSpace(string(stdout) + "\n" + string(stderr))
+		logger.Debug("doctor check", "manager", name, "command", check.Command, "err", err, "output", output)
+		switch {
+		case err != nil:
+			findings = append(findings, Finding{Check: label, Severity: check.Severity, Detail: DoctorDetail(err.Error(), output), Fix: check.Fix})
+		case check.FailOnOutput && output != "":
+			findings = append(findings, Finding{Check: label, Severity: check.Severity, Detail: DoctorDetail("problems reported", output), Fix: check.Fix})
+		default:
+			findings = append(findings, Finding{Check: label, Severity: SEVERITY_OK, Detail: "no problems"})
+		}
+	}
+	return findings
+}
+
+// Method to summarise the output of a failed check, keeping its first lines
+func DoctorDetail(summary string, output string) string {
+	// Initialise variables
+	lines := strings.Split(output, "\n")
+	if output == "" {
+		return summary
+	}
+	if len(lines) > 3 {
+		lines = append(lines[:3], "... ("+strconv.Itoa(len(lines)-3)+" more lines)")
+	}
+	return summary + ": " + strings.Join(lines, " | ")
+}
+
+// Method to check if the locks of a package manager are held, or left behind
+func CheckLocks(name string, locks []PkgLock) []Finding {
+	// Initialise variables
+	var findings []Finding
+
+	for _, lock := range locks {
+		label := name + ": lock " + lock.Path
+		if _, err := os.Stat(lock.Path); err != nil {
+			continue
+		}
+		switch lock.Kind {
+		case LOCK_FCNTL:
+			held, pid, err := LockHolder(lock.Path)
+			switch {
+			case os.IsPermission(err):
+				findings = append(findings, Finding{Check: label, Severity: SEVERITY_INFO, Detail: "cannot be checked without ROOT privileges", Fix: "run doctor as root"})
+			case err != nil:
+				findings = append(findings, Finding{Check: label, Severity: SEVERITY_INFO, Detail: "cannot be checked: " + err.Error()})
+			case held:
+				findings = append(findings, Finding{Check: label, Severity: SEVERITY_WARNING, Detail: "held by " + ProcessName(pid), Fix: "wait for it to finish (e.g. unattended upgrades) before updating"})
+			default:
+				findings = append(findings, Finding{Check: label, Severity: SEVERITY_OK, Detail: "free"})
+			}
+		case LOCK_EXISTS:
+			findings = append(findings, Finding{Check: label, Severity: SEVERITY_CRITICAL, Detail: "exists, so " + name + " is running or was interrupted", Fix: "if no " + name + " is running, remove it: sudo rm " + lock.Path})
+		case LOCK_PIDFILE:
+			content, err := os.ReadFile(lock.Path)
+			pid, parseErr := strconv.Atoi(strings.TrimSpace(string(content)))
+			switch {
+			case err != nil || parseErr != nil:
+				continue
+			case ProcessAlive(pid):
+				findings = append(findings, Finding{Check: label, Severity: SEVERITY_WARNING, Detail: "held by " + ProcessName(pid), Fix: "wait for it to finish before updating"})
+			default:
+				findings = append(findings, Finding{Check: label, Severity: SEVERITY_OK, Detail: "left by a finished process"})
+			}
+		}
+	}
+	return findings
+}
+
+// Method to describe a process by ID, with its name when known
+func ProcessName(pid int) string {
+	if pid < 0 {
+		return "another process"
+	}
+	description := "process " + strconv.Itoa(pid)
+	if comm, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/comm"); err == nil {
+		description += " (" + strings.TrimSpace(string(comm)) + ")"
+	}
+	return description
+}
+
+// Method to list the expired primary keys in gpg's colon listing, by key ID
+func ExpiredKeys(listing string, now time.Time) []string {
+	// Initialise variables
+	var expired []string
+
+	for _, line := range strings.Split(listing, "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) < 7 || fields[0] != "pub" {
+			continue
+		}
+		expires, err := strconv.ParseInt(fields[6], 10, 64)
+		if fields[1] == "e" || (err == nil && expires > 0 && now.Unix() > expires) {
+			expired = append(expired, fields[4])
+		}
+	}
+	return expired
+}
+
+// Method to check the keyrings trusted by apt for expired keys
+//
+// Repositories signed by an expired key fail to refresh, so the upgrade uses stale indexes.
+func CheckAptKeys() []Finding {
+	// Initialise variables
+	var findings []Finding
+	var keyrings []string
+
+	for _, path := range DOCTOR_APT_KEYRINGS {
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+			continue
+		case info.IsDir():
+			entries, _ := os.ReadDir(path)
+			for _, entry := range entries {
+				if strings.HasSuffix(entry.Name(), ".gpg") || strings.HasSuffix(entry.Name(), ".asc") {
+					keyrings = append(keyrings, filepath.Join(path, entry.Name()))
+				}
+			}
+		default:
+			keyrings = append(keyrings, path)
+		}
+	}
+	if len(keyrings) == 0 {
+		return nil
+	}
+	gpgPath, err := ResolveManager("gpg", false, nil)
+	if err != nil {
+		return []Finding{{Check: "apt keys", Severity: SEVERITY_INFO, Detail: "skipped, gpg not found"}}
+	}
+	// Keep gpg from creating a home directory for the user
+	home, err := os.MkdirTemp("", "update_full-gnupg-*")
+	if err != nil {
+		return []Finding{{Check: "apt keys", Severity: SEVERITY_INFO, Detail: "cannot be checked: " + err.Error()}}
+	}
+	defer os.RemoveAll(home)
+
+	for _, keyring := range keyrings {
+		label := "apt key " + keyring
+		stdout, stderr, err := RunCommand(CommandOptions{Env: []string{"GNUPGHOME=" + home}, Timeout: stepTimeout}, gpgPath, "--batch", "--with-colons", "--show-keys", keyring)
+		if err != nil {
+			findings = append(findings, Finding{Check: label, Severity: SEVERITY_INFO, Detail: DoctorDetail("cannot be checked", strings.TrimSpace(string(stderr)))})
+			continue
+		}
+		switch expired := ExpiredKeys(string(stdout), time.Now()); len(expired) {
+		case 0:
+			findings = append(findings, Finding{Check: label, Severity: SEVERITY_OK, Detail: "no expired keys"})
+		default:
+			findings = append(findings, Finding{Check: label, Severity: SEVERITY_WARNING, Detail: "expired keys " + strings.Join(expired, ", "), Fix: "fetch the current key from the repository's vendor, or remove the keyring if its repository is gone"})
+		}
+	}
+	return findings
+}
+
+// Method to check free space and inodes of the filesystems package managers write to
+func CheckDiskSpace() []Finding {
+	// Initialise variables
+	var findings []Finding
+	seen := map[uint64]bool{}
+
+	for _, path := range DOCTOR_DISK_PATHS {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		space, err := DiskUsage(path)
+		if err != nil {
+			findings = append(findings, Finding{Check: "disk " + path, Severity: SEVERITY_INFO, Detail: "cannot be checked: " + err.Error()})
+			break
+		}
+		// Check every filesystem once, by its first path
+		if seen[space.Device] {
+			continue
+		}
+		seen[space.Device] = true
+
+		warn, critical := uint64(minFreeSpace), DOCTOR_DISK_CRITICAL
+		if path == "/boot" {
+			warn, critical = uint64(minFreeBoot), DOCTOR_BOOT_CRITICAL
+		}
+		finding := Finding{Check: "disk " + path, Severity: SEVERITY_OK, Detail: FormatBytes(space.Free) + " free of " + FormatBytes(space.Total)}
+		switch {
+		case space.Free < critical:
+			finding.Severity = SEVERITY_CRITICAL
+		case space.Free < warn:
+			finding.Severity = SEVERITY_WARNING
+		}
+		if finding.Severity != SEVERITY_OK {
+			finding.Fix = "free space on " + path + ", e.g. by cleaning package caches or removing old kernels"
+		}
+		findings = append(findings, finding)
+
+		// Filesystems without inode limits report none
+		if space.TotalInodes == 0 {
+			continue
+		}
+		percent := space.FreeInodes * 100 / space.TotalInodes
+		finding = Finding{Check: "inodes " + path, Severity: SEVERITY_OK, Detail: strconv.FormatUint(percent, 10) + "% free"}
+		switch {
+		case percent < DOCTOR_INODES_CRITICAL:
+			finding.Severity = SEVERITY_CRITICAL
+		case percent < uint64(minFreeInodes):
+			finding.Severity = SEVERITY_WARNING
+		}
+		if finding.Severity != SEVERITY_OK {
+			finding.Fix = "remove many small files on " + path + " (e.g. old caches, logs or session files)"
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// Method to check the clock is synchronised, and agrees with the network target
+//
+// A wrong clock breaks TLS and makes repository metadata look expired or not yet valid.
+func CheckTime() []Finding {
+	// Initialise variables
+	var findings []Finding
+
+	// systemd reports whether NTP synchronised the clock
+	if path, err := ResolveManager("timedatectl", false, nil); err == nil {
+		stdout, _, err := RunCommand(CommandOptions{}, path, "show", "-p", "NTPSynchronized", "--value")
+		switch {
+		case err != nil:
+			findings = append(findings, Finding{Check: "time sync", Severity: SEVERITY_INFO, Detail: "cannot be checked: " + err.Error()})
+		case strings.TrimSpace(string(stdout)) == "yes":
+			findings = append(findings, Finding{Check: "time sync", Severity: SEVERITY_OK, Detail: "synchronised (NTP)"})
+		default:
+			findings = append(findings, Finding{Check: "time sync", Severity: SEVERITY_WARNING, Detail: "clock is not synchronised", Fix: "enable time synchronisation: sudo timedatectl set-ntp true"})
+		}
+	}
+
+	// Compare with the Date of the network target, unless offline
+	if offlineFlag {
+		return findings
+	}
+	target, err := ParseNetworkTarget(DEFAULT_NETWORK_TARGET)
+	if err != nil {
+		return findings
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), networkTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, target.String(), nil)
+	if err != nil {
+		return findings
+	}
+	sent := time.Now()
+	response, err := NewConnectivityChecker().HTTPClient().Do(request)
+	if err != nil {
+		return append(findings, Finding{Check: "clock skew", Severity: SEVERITY_INFO, Detail: "cannot be checked: " + err.Error()})
+	}
+	response.Body.Close()
+	remote, err := http.ParseTime(response.Header.Get("Date"))
+	if err != nil {
+		return append(findings, Finding{Check: "clock skew", Severity: SEVERITY_INFO, Detail: "cannot be checked, no Date from " + DEFAULT_NETWORK_TARGET})
+	}
+	// Compare against the middle of the request, as Date has a resolution of a second
+	skew := sent.Add(time.Since(sent) / 2).Sub(remote).Round(time.Second)
+	finding := Finding{Check: "clock skew", Severity: SEVERITY_OK, Detail: skew.String() + " from " + DEFAULT_NETWORK_TARGET}
+	switch {
+	case skew.Abs() > DOCTOR_SKEW_CRITICAL:
+		finding.Severity = SEVERITY_CRITICAL
+	case skew.Abs() > DOCTOR_SKEW_WARN:
+		finding.Severity = SEVERITY_WARNING
+	}
+	if finding.Severity != SEVERITY_OK {
+		finding.Fix = "set the clock, and enable time synchronisation"
+	}
+	return append(findings, finding)
+}
+
+// Method to check privilege escalation, without prompting
+func CheckPrivileges() Finding {
+	currentUser, err := user.Current()
+	switch {
+	case err != nil:
+		return Finding{Check: "privileges", Severity: SEVERITY_CRITICAL, Detail: err.Error()}
+	case OS_TYPE == "windows" || currentUser.Username == "root":
+		return Finding{Check: "privileges", Severity: SEVERITY_OK, Detail: "running as " + currentUser.Username}
+	case userOnlyFlag:
+		return Finding{Check: "privileges", Severity: SEVERITY_OK, Detail: "user-only, no escalation needed"}
+	}
+	probe, err := NewEscalationProber(currentUser.Username).Choose(escalateFlag)
+	if err != nil {
+		return Finding{Check: "privileges", Severity: SEVERITY_CRITICAL, Detail: err.Error(), Fix: "run as root, or set up sudo, doas, run0 or pkexec"}
+	}
+	return Finding{Check: "privileges", Severity: SEVERITY_OK, Detail: "[" + probe.Method + "] " + probe.Reason}
+}
+
+// Method to find the label and color of a severity
+func SeverityLabel(severity int) (string, string) {
+	switch severity {
+	case SEVERITY_OK:
+		return "OK", COLOR_GREEN
+	case SEVERITY_INFO:
+		return "INFO", COLOR_DIM
+	case SEVERITY_WARNING:
+		return "WARN", COLOR_YELLOW
+	default:
+		return "CRIT", COLOR_RED
+	}
+}
+
+// Method to print a finding, with its fix
+func PrintFinding(finding Finding) {
+	// Passing checks are only shown when verbose
+	if finding.Severity == SEVERITY_OK && !verboseFlag {
+		return
+	}
+	label, color := SeverityLabel(finding.Severity)
+	fmt.Println("\t" + Colorize(color, "["+label+"]") + " " + finding.Check + ": " + finding.Detail)
+	if finding.Fix != "" {
+		fmt.Println("\t\t-> " + finding.Fix)
+	}
+}
+
+// Method to run the doctor command: check what usually blocks updates, without changing anything
+//
+// Exits with 0 when only informational findings remain, 1 on warnings, and 2 on critical findings.
+func DoctorCommand() int {
+	// Initialise variables
+	var findings []Finding
+
+	fmt.Println("* Checking update_full " + LONG_VERSION_NUM + " on " + OS_TYPE)
+
+	// Package managers, where they are run from, and their own checks
+	detected := DetectedManagers(altOnlyFlag, officialOnlyFlag, yumUpdateFlag)
+	if len(detected) == 0 {
+		findings = append(findings, Finding{Check: "package managers", Severity: SEVERITY_CRITICAL, Detail: "none detected"})
+	}
+	for _, manager := range detected {
+		name := PkgManagerName(manager.PkgNum, manager.Official)
+		elevated := !IsUserLevelManager(manager.PkgNum, manager.Official)
+		path, err := ResolveManager(name, elevated, InvokingUser())
+		switch err {
+		case nil:
+			findings = append(findings, Finding{Check: name, Severity: SEVERITY_OK, Detail: path})
+		default:
+			findings = append(findings, Finding{Check: name, Severity: SEVERITY_CRITICAL, Detail: err.Error(), Fix: "install " + name + " from a trusted location, owned by root"})
+		}
+		// Checks would only fail on a held lock, so skip them
+		lockFindings := CheckLocks(name, DoctorLocks(manager.PkgNum, manager.Official))
+		findings = append(findings, lockFindings...)
+		if slices.ContainsFunc(lockFindings, func(finding Finding) bool { return finding.Severity >= SEVERITY_WARNING }) {
+			findings = append(findings, Finding{Check: name, Severity: SEVERITY_INFO, Detail: "further checks skipped while locked"})
+			continue
+		}
+		findings = append(findings, RunDoctorChecks(name, DoctorChecks(manager.PkgNum, manager.Official))...)
+		if manager.Official && manager.PkgNum == 0 {
+			findings = append(findings, CheckAptKeys()...)
+		}
+	}
+
+	// The system
+	findings = append(findings, CheckPrivileges())
+	findings = append(findings, CheckDiskSpace()...)
+	findings = append(findings, CheckTime()...)
+	if state, err := ReadPowerState(sysfsRoot); err == nil && state.Batteries > 0 {
+		switch PowerSufficient(state) {
+		case true:
+			findings = append(findings, Finding{Check: "power", Severity: SEVERITY_OK, Detail: state.String()})
+		default:
+			findings = append(findings, Finding{Check: "power", Severity: SEVERITY_WARNING, Detail: state.String() + ", below --min-battery " + strconv.Itoa(minBattery) + "%", Fix: "connect AC power before updating"})
+		}
+	}
+	if OS_TYPE != "windows" && RebootRequired() {
+		findings = append(findings, Finding{Check: "reboot", Severity: SEVERITY_INFO, Detail: "a reboot is pending from earlier updates", Fix: "reboot, or run with --reboot=if-required"})
+	}
+
+	for _, finding := range findings {
+		PrintFinding(finding)
+	}
+	exitCode := DoctorExitCode(findings)
+	logger.Info("doctor finished", "findings", len(findings), "exit_code", exitCode)
+
+	switch exitCode {
+	case DOCTOR_EXIT_CRITICAL:
+		PrintFailure("!!Critical problems found, updates will likely fail")
+	case DOCTOR_EXIT_WARNINGS:
+		PrintWarning("!!Warnings found, updates may fail")
+	default:
+		PrintSuccess("* No problems found")
+	}
+	return exitCode
+}
+
+// Method to find the exit code of the doctor command from its worst finding
+//
+// Informational findings do not count as problems.
+func DoctorExitCode(findings []Finding) int {
+	// Initialise variables
+	worst := SEVERITY_OK
+	for _, finding := range findings {
+		worst = max(worst, finding.Severity)
+	}
+
+	switch worst {
+	case SEVERITY_CRITICAL:
+		return DOCTOR_EXIT_CRITICAL
+	case SEVERITY_WARNING:
+		return DOCTOR_EXIT_WARNINGS
+	}
+	return 0
+}
@@ -0,0 +1,61 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Tests of the doctor command's checks
+
+package main
+
+// Import packages
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpiredKeys(t *testing.T) {
+	// Initialise variables
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name    string
+		listing string
+		want    []string
+	}{
+		{"flagged expired", "pub:e:2048:1:E20D3F7A4645CF08:1577836800:1609372800::u:::sc::::::23::0:\nfpr:::::::::B3758C042FD9A150AF14FE86E20D3F7A4645CF08:\n", []string{"E20D3F7A4645CF08"}},
+		{"expiry in the past", "pub:-:4096:1:0E98404D386FA1D9:1609452000:1673524800::-:::scSC::::::23::0:\n", []string{"0E98404D386FA1D9"}},
+		{"expiry in the future", "pub:-:4096:1:6ED0E7B82643E131:1673524800:1862740800::-:::scSC::::::23::0:\n", nil},
+		{"no expiry", "pub:-:4096:22:A5F8E2F3B42D2E07:1673524800:::-:::scSC::::::23::0:\n", nil},
+		{"expired subkey only", "pub:-:4096:1:6ED0E7B82643E131:1673524800:::-:::scSC::::::23::0:\nsub:e:4096:1:1111111111111111:1577836800:1609372800:::::s::::::23:\n", nil},
+		{"empty", "", nil},
+	}
+	for _, test := range tests {
+		if got := ExpiredKeys(test.listing, now); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: ExpiredKeys() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDoctorExitCode(t *testing.T) {
+	tests := []struct {
+		name       string
+		severities []int
+		want       int
+	}{
+		{"no findings", nil, 0},
+		{"all OK", []int{SEVERITY_OK, SEVERITY_OK}, 0},
+		{"informational only", []int{SEVERITY_OK, SEVERITY_INFO}, 0},
+		{"warning", []int{SEVERITY_INFO, SEVERITY_WARNING, SEVERITY_OK}, DOCTOR_EXIT_WARNINGS},
+		{"critical", []int{SEVERITY_CRITICAL, SEVERITY_OK}, DOCTOR_EXIT_CRITICAL},
+		{"critical beats warning", []int{SEVERITY_WARNING, SEVERITY_CRITICAL, SEVERITY_WARNING}, DOCTOR_EXIT_CRITICAL},
+	}
+	for _, test := range tests {
+		// Initialise variables
+		var findings []Finding
+		for _, severity := range test.severities {
+			findings = append(findings, Finding{Check: "test", Severity: severity})
+		}
+		if got := DoctorExitCode(findings); got != test.want {
+			t.Errorf("%s: DoctorExitCode() = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,426 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Privilege escalation: choosing between sudo, doas, pkexec and run0
+
+package main
+
+// Import packages
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+	"time"
+)
+
+// // Supported escalation methods, in order of preference
+const ESCALATE_AUTO string = "auto"
+const ESCALATE_NONE string = "none"
+
+var ESCALATION_METHODS []string = []string{"sudo", "doas", "run0", "pkexec"}
+
+// // Groups granted administrator rights by default polkit rules
+var POLKIT_ADMIN_GROUPS []string = []string{"wheel", "sudo", "admin"}
+
+// // Groups usually granted sudo by distributions' default sudoers
+var SUDO_GROUPS []string = []string{"sudo", "wheel", "admin"}
+
+// // Locations of doas.conf (Linux, then BSD ports)
+var DOAS_CONF_PATHS []string = []string{"/etc/doas.conf", "/usr/local/etc/doas.conf"}
+
+// // Interval between refreshes of cached sudo credentials
+const SUDO_KEEPALIVE_INTERVAL time.Duration = time.Minute
+
+// // Closed to stop refreshing sudo credentials
+var sudoKeepAliveStop chan struct{}
+
+// // Escalation setting, set by flags
+var escalateFlag string = ESCALATE_AUTO
+
+// Result of probing a single escalation method
+type EscalationProbe struct {
+	Method string
+	// Absolute path of the method's binary, found in TRUSTED_PATH_DIRS
+	Path          string
+	Available     bool
+	NeedsPassword bool
+	Reason        string
+}
+
+// Information used to probe escalation methods
+type EscalationProber struct {
+	Username      string
+	Groups        []string
+	DoasConfPaths []string
+	Interactive   bool
+}
+
+// Method to create an EscalationProber for the current user
+func NewEscalationProber(username string) *EscalationProber {
+	// Initialise variables
+	prober := &EscalationProber{
+		Username:      username,
+		DoasConfPaths: DOAS_CONF_PATHS,
+		Interactive:   IsTerminal(os.Stdin),
+	}
+	// Find names of the user's groups
+	currentUser, err := user.Lookup(username)
+	if err != nil {
+		return prober
+	}
+	groupIds, _ := currentUser.GroupIds()
+	for _, groupId := range groupIds {
+		if group, err := user.LookupGroupId(groupId); err == nil {
+			prober.Groups = append(prober.Groups, group.Name)
+		}
+	}
+	return prober
+}
+
+// Method to run a probing command, returning its combined output
+//
+// The command gets a minimal environment, and messages are matched in English, so it
+// runs in the C locale.
+func ProbeCommand(path string, args ...string) (string, error) {
+	var output bytes.Buffer
+	command := exec.Command(path, args...)
+	command.Env = MinimalEnv(os.Getenv("HOME"), []string{"LC_ALL=C"})
+	command.Stdout = &output
+	command.Stderr = &output
+	err := command.Run()
+	return strings.TrimSpace(output.String()), err
+}
+
+// Method to find the binary of an escalation method in TRUSTED_PATH_DIRS
+//
+// The user's password is typed into these binaries, so one planted earlier in a
+// user-writable PATH must never be run.
+func ResolveEscalation(probe *EscalationProbe) bool {
+	path, err := ResolveManager(probe.Method, true, nil)
+	if err != nil {
+		probe.Reason = probe.Method + " not usable: " + err.Error()
+		return false
+	}
+	probe.Path = path
+	return true
+}
+
+// Method to check if the user belongs to any of the given groups
+func (prober *EscalationProber) InGroup(groups []string) bool {
+	for _, group := range prober.Groups {
+		for _, wanted := range groups {
+			if group == wanted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Method to probe sudo, without prompting for a password
+func (prober *EscalationProber) ProbeSudo() EscalationProbe {
+	probe := EscalationProbe{Method: "sudo"}
+	if !ResolveEscalation(&probe) {
+		return probe
+	}
+	output, err := ProbeCommand(probe.Path, "-n", "true")
+	switch {
+	case err == nil:
+		probe.Available = true
+		probe.Reason = "sudo works without a password (NOPASSWD or cached credentials)"
+	case strings.Contains(output, "password is required"):
+		// Users missing from sudoers are asked for a password too, so confirm they may use sudo
+		probe.NeedsPassword = true
+		rules, err := ProbeCommand(probe.Path, "-n", "-l")
+		switch {
+		case err == nil && strings.Contains(rules, "may run the following commands"):
+			probe.Available = true
+			probe.Reason = "sudo needs a password (rules listed by sudo -l)"
+		case prober.InGroup(SUDO_GROUPS):
+			probe.Available = true
+			probe.Reason = "sudo needs a password (user is in a sudo group)"
+		default:
+			probe.Reason = "sudo needs a password, but user is neither listed by sudo -l nor in a sudo group (" + strings.Join(SUDO_GROUPS, ", ") + ")"
+		}
+	default:
+		probe.Reason = "sudo refused: " + output
+	}
+	return probe
+}
+
+// Method to evaluate doas.conf rules allowing the user to run any command as root
+//
+// As in doas itself, the last matching rule wins. Rules restricted by "cmd" do not
+// allow arbitrary package managers, so they do not count as permitting.
+func (prober *EscalationProber) DoasConfPermits(path string) (permit bool, nopass bool, matched bool) {
+	for _, line := range ReadConfigLines(path) {
+		line, _, _ = strings.Cut(line, "#")
+		tokens := strings.Fields(line)
+		if len(tokens) < 2 || (tokens[0] != "permit" && tokens[0] != "deny") {
+			continue
+		}
+		// Read options, including setenv { ... } blocks
+		var ruleNopass bool
+		var optionsDone bool
+		var position int = 1
+		for position < len(tokens) && !optionsDone {
+			switch tokens[position] {
+			case "nopass":
+				ruleNopass = true
+				position++
+			case "nolog", "persist", "keepenv":
+				position++
+			case "setenv":
+				for position < len(tokens) && !strings.HasSuffix(tokens[position], "}") {
+					position++
+				}
+				position++
+			default:
+				optionsDone = true
+			}
+		}
+		if position >= len(tokens) {
+			continue
+		}
+		identity := tokens[position]
+		rest := tokens[position+1:]
+		// Match identity: a user name, or ":group"
+		switch strings.HasPrefix(identity, ":") {
+		case true:
+			if !prober.InGroup([]string{identity[1:]}) {
+				continue
+			}
+		case false:
+			if identity != prober.Username {
+				continue
+			}
+		}
+		// Match target: missing or root
+		if len(rest) >= 2 && rest[0] == "as" {
+			if rest[1] != "root" {
+				continue
+			}
+			rest = rest[2:]
+		}
+		// Rules restricted to a command only matter when denying
+		if len(rest) > 0 && rest[0] == "cmd" && tokens[0] == "permit" {
+			continue
+		}
+		permit, nopass, matched = tokens[0] == "permit", ruleNopass, true
+	}
+	return permit, nopass, matched
+}
+
+// Method to probe doas, using its configuration
+func (prober *EscalationProber) ProbeDoas() EscalationProbe {
+	probe := EscalationProbe{Method: "doas"}
+	if !ResolveEscalation(&probe) {
+		return probe
+	}
+	if _, err := ProbeCommand(probe.Path, "-n", "true"); err == nil {
+		probe.Available = true
+		probe.Reason = "doas works without a password (nopass or persisted)"
+		return probe
+	}
+	for _, path := range prober.DoasConfPaths {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		permit, nopass, matched := prober.DoasConfPermits(path)
+		switch {
+		case !matched:
+			probe.Reason = "no rule in " + path + " matches user " + prober.Username
+		case !permit:
+			probe.Reason = "denied by " + path
+		default:
+			probe.Available = true
+			probe.NeedsPassword = !nopass
+			probe.Reason = "permitted by " + path
+		}
+		return probe
+	}
+	probe.Reason = "doas.conf not found or not readable"
+	return probe
+}
+
+// Method to probe polkit-based methods (pkexec, run0)
+func (prober *EscalationProber) ProbePolkit(method string) EscalationProbe {
+	probe := EscalationProbe{Method: method}
+	if !ResolveEscalation(&probe) {
+		return probe
+	}
+	// Only run0 can be asked not to prompt
+	if method == "run0" {
+		if _, err := ProbeCommand(probe.Path, "--no-ask-password", "true"); err == nil {
+			probe.Available = true
+			probe.Reason = "run0 works without a password"
+			return probe
+		}
+	}
+	switch prober.InGroup(POLKIT_ADMIN_GROUPS) {
+	case true:
+		probe.Available = true
+		probe.NeedsPassword = true
+		probe.Reason = "user is in a polkit administrator group"
+	case false:
+		probe.Reason = "user is not in a polkit administrator group (" + strings.Join(POLKIT_ADMIN_GROUPS, ", ") + ")"
+	}
+	return probe
+}
+
+// Method to probe a single escalation method by name
+func (prober *EscalationProber) Probe(method string) EscalationProbe {
+	switch method {
+	case "sudo":
+		return prober.ProbeSudo()
+	case "doas":
+		return prober.ProbeDoas()
+	default:
+		return prober.ProbePolkit(method)
+	}
+}
+
+// Method to choose an escalation method, honouring an explicit override
+func (prober *EscalationProber) Choose(override string) (EscalationProbe, error) {
+	// Initialise variables
+	var reasons []string
+
+	switch override {
+	case ESCALATE_NONE:
+		return EscalationProbe{Method: "", Available: true, Reason: "escalation disabled by --escalate=none"}, nil
+	case ESCALATE_AUTO:
+		for _, method := range ESCALATION_METHODS {
+			probe := prober.Probe(method)
+			logger.Debug("escalation probe", "method", method, "available", probe.Available, "needs_password", probe.NeedsPassword, "reason", probe.Reason)
+			// Methods needing a password are only usable with a terminal
+			if probe.Available && (!probe.NeedsPassword || prober.Interactive) {
+				return probe, nil
+			}
+			if probe.Available {
+				probe.Reason += ", but no terminal is attached" + NonInteractiveHint(method)
+			}
+			reasons = append(reasons, probe.Reason)
+		}
+		return EscalationProbe{}, errors.New("no usable privilege escalation: " + strings.Join(reasons, "; "))
+	default:
+		probe := prober.Probe(override)
+		if !probe.Available {
+			return probe, errors.New("--escalate=" + override + " is not usable: " + probe.Reason)
+		}
+		if probe.NeedsPassword && !prober.Interactive {
+			return probe, errors.New("--escalate=" + override + " needs a password, but no terminal is attached" + NonInteractiveHint(override))
+		}
+		probe.Reason = "chosen by --escalate (" + probe.Reason + ")"
+		return probe, nil
+	}
+}
+
+// Method to suggest how to run an escalation method without a terminal
+func NonInteractiveHint(method string) string {
+	switch method {
+	case "sudo":
+		return " (run \"sudo -v\" first in the same session, or configure NOPASSWD)"
+	case "doas":
+		return " (configure a nopass rule in doas.conf)"
+	default:
+		return " (configure a polkit rule allowing it without authentication)"
+	}
+}
+
+// Method to check that --escalate names a known method
+func ValidateEscalation(method string) bool {
+	if method == ESCALATE_AUTO || method == ESCALATE_NONE {
+		return true
+	}
+	for _, known := range ESCALATION_METHODS {
+		if method == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Method to list the values accepted by --escalate, for shell completion
+func EscalationChoices(config *Config) []string {
+	return append(append([]string{ESCALATE_AUTO}, ESCALATION_METHODS...), ESCALATE_NONE)
+}
+
+// Method to print and report the chosen escalation method
+func PrintEscalation(probe EscalationProbe) {
+	method := probe.Method
+	if method == "" {
+		method = "none"
+	}
+	PrintStatus("\t* Privilege escalation: [" + method + "] (" + probe.Reason + ")")
+	runReport.AddNote("Privilege escalation: " + method + " (" + probe.Reason + ")")
+}
+
+// Method to prepare the chosen escalation method before any package manager runs
+//
+// sudo is authenticated once, with the terminal attached, and kept alive for the whole run.
+// Methods prompting for every command keep package managers attached to the terminal,
+// so every prompt can be answered.
+func PrepareEscalation(probe EscalationProbe) error {
+	switch probe.Method {
+	case "":
+		return nil
+	case "sudo":
+		if probe.NeedsPassword {
+			command := exec.Command(probe.Path, "-v")
+			command.Env = MinimalEnv(os.Getenv("HOME"), nil)
+			command.Stdin = os.Stdin
+			command.Stdout = os.Stdout
+			command.Stderr = os.Stderr
+			if err := command.Run(); err != nil {
+				return errors.New("sudo authentication failed: " + err.Error())
+			}
+		}
+		// Credentials may not be cached at all (e.g. timestamp_timeout=0)
+		if _, err := ProbeCommand(probe.Path, "-n", "true"); err != nil {
+			PromptEveryStep(probe.Method)
+			return nil
+		}
+		StartSudoKeepAlive(probe.Path)
+	default:
+		if probe.NeedsPassword {
+			PromptEveryStep(probe.Method)
+		}
+	}
+	return nil
+}
+
+// Method to keep package managers attached to the terminal, for methods prompting every step
+func PromptEveryStep(method string) {
+	detachChildren = false
+	PrintWarning("\t* [" + method + "] may prompt for every step, Ctrl-C will reach the package manager directly")
+}
+
+// Method to refresh cached sudo credentials in the background, so long runs do not hang
+func StartSudoKeepAlive(sudoPath string) {
+	sudoKeepAliveStop = make(chan struct{})
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(SUDO_KEEPALIVE_INTERVAL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if output, err := ProbeCommand(sudoPath, "-n", "-v"); err != nil {
+					PrintWarning("!!Could not refresh sudo credentials:", output)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}(sudoKeepAliveStop)
+}
+
+// Method to stop refreshing sudo credentials
+func StopSudoKeepAlive() {
+	if sudoKeepAliveStop != nil {
+		close(sudoKeepAliveStop)
+		sudoKeepAliveStop = nil
+	}
+}
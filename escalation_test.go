@@ -0,0 +1,165 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Tests of privilege escalation probing, with fake escalation binaries placed in PATH
+
+package main
+
+// Import packages
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// // Fake sudo binaries: one succeeding, and ones asking for a password (in English only under LC_ALL=C)
+const FAKE_SUDO_NOPASSWD string = "exit 0\n"
+const FAKE_SUDO_PASSWORD string = `[ "$LC_ALL" = C ] || { echo "sudo: Ein Passwort ist notwendig" >&2; exit 1; }
+echo "sudo: a password is required" >&2
+exit 1
+`
+const FAKE_SUDO_LISTED string = `if [ "$2" = -l ]; then echo "User test may run the following commands on host:"; echo "    (ALL : ALL) ALL"; exit 0; fi
+echo "sudo: a password is required" >&2
+exit 1
+`
+
+// Method to place fake escalation binaries (shell script bodies) in a directory, used as the only trusted one
+//
+// Temporary directories are neither owned by root nor safe from other users, so the trust check is skipped.
+func FakeBinaries(t *testing.T, scripts map[string]string) {
+	// Initialise variables
+	dir := t.TempDir()
+	savedDirs, savedCheck := TRUSTED_PATH_DIRS, checkTrustedBinary
+	t.Cleanup(func() { TRUSTED_PATH_DIRS, checkTrustedBinary = savedDirs, savedCheck })
+
+	for name, body := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+body), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	TRUSTED_PATH_DIRS = []string{dir}
+	checkTrustedBinary = func(path string) error { return nil }
+}
+
+func TestProbeSudo(t *testing.T) {
+	tests := []struct {
+		name              string
+		sudo              string
+		groups            []string
+		wantAvailable     bool
+		wantNeedsPassword bool
+	}{
+		{"not installed", "", nil, false, false},
+		{"nopasswd", FAKE_SUDO_NOPASSWD, nil, true, false},
+		{"password, in sudo group", FAKE_SUDO_PASSWORD, []string{"users", "wheel"}, true, true},
+		{"password, listed by sudo -l", FAKE_SUDO_LISTED, []string{"users"}, true, true},
+		{"password, not in sudoers", FAKE_SUDO_PASSWORD, []string{"users"}, false, true},
+		{"refused", "echo 'sudo: account validation failure' >&2; exit 1\n", []string{"wheel"}, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Initialise variables
+			scripts := map[string]string{}
+			if test.sudo != "" {
+				scripts["sudo"] = test.sudo
+			}
+			FakeBinaries(t, scripts)
+
+			prober := &EscalationProber{Username: "test", Groups: test.groups}
+			probe := prober.ProbeSudo()
+			if probe.Available != test.wantAvailable || probe.NeedsPassword != test.wantNeedsPassword {
+				t.Errorf("probe = %+v, want available %v, needs password %v", probe, test.wantAvailable, test.wantNeedsPassword)
+			}
+		})
+	}
+}
+
+func TestDoasConfPermits(t *testing.T) {
+	tests := []struct {
+		name        string
+		conf        string
+		wantPermit  bool
+		wantNopass  bool
+		wantMatched bool
+	}{
+		{"user rule", "permit test as root\n", true, false, true},
+		{"group rule with nopass", "permit nopass :wheel\n", true, true, true},
+		{"setenv block", "permit persist setenv { PATH=/bin FOO=bar } :wheel\n", true, false, true},
+		{"other user", "permit alice\n", false, false, false},
+		{"other target", "permit test as www\n", false, false, false},
+		{"restricted command", "permit nopass test cmd /usr/bin/apt\n", false, false, false},
+		{"last match wins", "permit :wheel\ndeny test\n", false, false, true},
+		{"comments", "# permit test\npermit nopass test # trailing\n", true, true, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Initialise variables
+			path := filepath.Join(t.TempDir(), "doas.conf")
+			if err := os.WriteFile(path, []byte(test.conf), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			prober := &EscalationProber{Username: "test", Groups: []string{"wheel"}}
+			permit, nopass, matched := prober.DoasConfPermits(path)
+			if permit != test.wantPermit || nopass != test.wantNopass || matched != test.wantMatched {
+				t.Errorf("permits = %v, %v, %v, want %v, %v, %v", permit, nopass, matched, test.wantPermit, test.wantNopass, test.wantMatched)
+			}
+		})
+	}
+}
+
+func TestChooseEscalation(t *testing.T) {
+	tests := []struct {
+		name        string
+		scripts     map[string]string
+		groups      []string
+		interactive bool
+		override    string
+		wantMethod  string
+		wantErr     bool
+	}{
+		{"sudo without password", map[string]string{"sudo": FAKE_SUDO_NOPASSWD, "doas": "exit 0\n"}, nil, false, ESCALATE_AUTO, "sudo", false},
+		{"sudo password with terminal", map[string]string{"sudo": FAKE_SUDO_PASSWORD}, []string{"sudo"}, true, ESCALATE_AUTO, "sudo", false},
+		{"sudo password without terminal falls back to doas", map[string]string{"sudo": FAKE_SUDO_PASSWORD, "doas": "exit 0\n"}, []string{"sudo"}, false, ESCALATE_AUTO, "doas", false},
+		{"not in sudoers falls back to run0", map[string]string{"sudo": FAKE_SUDO_PASSWORD, "run0": "exit 0\n"}, nil, false, ESCALATE_AUTO, "run0", false},
+		{"pkexec in admin group", map[string]string{"pkexec": "exit 1\n"}, []string{"wheel"}, true, ESCALATE_AUTO, "pkexec", false},
+		{"nothing usable", map[string]string{"sudo": FAKE_SUDO_PASSWORD}, nil, true, ESCALATE_AUTO, "", true},
+		{"override", map[string]string{"sudo": FAKE_SUDO_NOPASSWD, "doas": "exit 0\n"}, nil, false, "doas", "doas", false},
+		{"override not installed", map[string]string{"sudo": FAKE_SUDO_NOPASSWD}, nil, false, "doas", "", true},
+		{"override needs password without terminal", map[string]string{"sudo": FAKE_SUDO_PASSWORD}, []string{"sudo"}, false, "sudo", "", true},
+		{"none", nil, nil, false, ESCALATE_NONE, "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			FakeBinaries(t, test.scripts)
+
+			prober := &EscalationProber{Username: "test", Groups: test.groups, Interactive: test.interactive}
+			probe, err := prober.Choose(test.override)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Choose(%q) error = %v, want error %v", test.override, err, test.wantErr)
+			}
+			if err == nil && probe.Method != test.wantMethod {
+				t.Errorf("Choose(%q) = %q (%s), want %q", test.override, probe.Method, probe.Reason, test.wantMethod)
+			}
+			if err != nil && !strings.Contains(err.Error(), "escalat") {
+				t.Errorf("error %q does not explain the escalation failure", err)
+			}
+		})
+	}
+}
+
+func TestProbeIgnoresEscalationInPath(t *testing.T) {
+	// Initialise variables
+	planted := t.TempDir()
+	if err := os.WriteFile(filepath.Join(planted, "sudo"), []byte("#!/bin/sh\n"+FAKE_SUDO_NOPASSWD), 0755); err != nil {
+		t.Fatal(err)
+	}
+	FakeBinaries(t, nil)
+	t.Setenv("PATH", planted+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	prober := &EscalationProber{Username: "test", Groups: []string{"sudo"}}
+	if probe := prober.ProbeSudo(); probe.Available || probe.Path != "" {
+		t.Errorf("sudo planted in PATH was used: %+v", probe)
+	}
+}
@@ -0,0 +1,225 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Run history: a JSON line per run, shown by the history command
+
+package main
+
+// Import packages
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// // Results of a run, as recorded in the history
+const RESULT_OK string = "ok"
+const RESULT_FAILED string = "failed"
+const RESULT_CANCELLED string = "cancelled"
+
+// // History settings, set by flags
+var historyFile string
+var noHistoryFlag bool
+var historyLimit int = 10
+
+// A single step of a run, as recorded in the history
+type HistoryStep struct {
+	Manager  string        `json:"manager"`
+	Command  string        `json:"command"`
+	Status   string        `json:"status"`
+	Attempt  int           `json:"attempt"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// A single run, as recorded in the history
+type HistoryEntry struct {
+	Begin    time.Time     `json:"begin"`
+	Duration time.Duration `json:"duration_ns"`
+	Version  string        `json:"version"`
+	Profile  string        `json:"profile,omitempty"`
+	Result   string        `json:"result"`
+	Notes    []string      `json:"notes,omitempty"`
+	Steps    []HistoryStep `json:"steps"`
+}
+
+// Method to find the default history file: system-wide for root, per-user otherwise
+func DefaultHistoryPath() string {
+	if OS_TYPE != "windows" && os.Geteuid() == 0 {
+		return "/var/lib/update_full/history.jsonl"
+	}
+	if stateDir := os.Getenv("XDG_STATE_HOME"); stateDir != "" {
+		return filepath.Join(stateDir, "update_full", "history.jsonl")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "update_full-history.jsonl")
+	}
+	return filepath.Join(home, ".local", "state", "update_full", "history.jsonl")
+}
+
+// Method to find the history file in use
+func HistoryPath() string {
+	if historyFile != "" {
+		return historyFile
+	}
+	return DefaultHistoryPath()
+}
+
+// Method to build the history entry of the report, redacting secrets
+func (report *RunReport) HistoryEntry() HistoryEntry {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+
+	// Initialise variables
+	entry := HistoryEntry{
+		Begin:    report.Begin,
+		Duration: time.Since(report.Begin),
+		Version:  LONG_VERSION_NUM,
+		Profile:  activeProfile,
+		Result:   RESULT_OK,
+		Steps:    []HistoryStep{},
+	}
+	for _, note := range report.Notes {
+		entry.Notes = append(entry.Notes, RedactSecrets(note))
+	}
+	for _, step := range report.Steps {
+		recorded := HistoryStep{
+			Manager:  step.Manager,
+			Command:  RedactSecrets(strings.Join(step.Command, " ")),
+			Status:   step.Status,
+			Attempt:  step.Attempt,
+			Duration: step.Duration,
+		}
+		if step.Err != nil {
+			recorded.Error = RedactSecrets(step.Err.Error())
+		}
+		if step.Status == STEP_FAILED {
+			entry.Result = RESULT_FAILED
+		}
+		entry.Steps = append(entry.Steps, recorded)
+	}
+	if report.Cancelled {
+		entry.Result = RESULT_CANCELLED
+	}
+	return entry
+}
+
+// Method to append the current run to the history, unless disabled
+//
+// A history that cannot be written only warns, it never fails the run.
+func RecordHistory() {
+	if noHistoryFlag {
+		return
+	}
+	path := HistoryPath()
+	line, err := json.Marshal(runReport.HistoryEntry())
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0o755)
+	}
+	var file *os.File
+	if err == nil {
+		file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+	}
+	if err == nil {
+		_, err = file.Write(append(line, '\n'))
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		PrintWarning("!!Could NOT record run in history ["+path+"]:", err)
+		logger.Warn("history not recorded", "path", path, "err", err)
+		return
+	}
+	logger.Debug("history recorded", "path", path)
+}
+
+// Method to read every run recorded in the history, oldest first
+func ReadHistory(path string) ([]HistoryEntry, error) {
+	// Initialise variables
+	var entries []HistoryEntry
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		var entry HistoryEntry
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return entries, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// Method to run the history command, printing the last runs
+func HistoryCommand() int {
+	// Initialise variables
+	path := HistoryPath()
+	if historyLimit < 1 {
+		fmt.Println("!!Invalid history limit [--limit >= 1]")
+		return 1
+	}
+
+	entries, err := ReadHistory(path)
+	switch {
+	case os.IsNotExist(err):
+		fmt.Println("* No runs recorded in [" + path + "]")
+		return 0
+	case err != nil:
+		PrintFailure("!!Could NOT read history:", err)
+		return 1
+	}
+	if len(entries) > historyLimit {
+		entries = entries[len(entries)-historyLimit:]
+	}
+
+	fmt.Println("* Last " + fmt.Sprint(len(entries)) + " run(s) recorded in [" + path + "]:")
+	for _, entry := range entries {
+		// Initialise variables
+		var failed []string
+		for _, step := range entry.Steps {
+			if step.Status == STEP_FAILED {
+				failed = append(failed, step.Manager)
+			}
+		}
+		line := fmt.Sprintf("\t%s %s (%s, %d step(s))", entry.Begin.Local().Format("2006-01-02 15:04:05"), Colorize(HistoryColor(entry.Result), "["+strings.ToUpper(entry.Result)+"]"), entry.Duration.Round(time.Second), len(entry.Steps))
+		if entry.Profile != "" {
+			line += " profile [" + entry.Profile + "]"
+		}
+		fmt.Println(line)
+		if len(failed) > 0 {
+			fmt.Println("\t\t* Failed: " + strings.Join(failed, ", "))
+		}
+		if verboseFlag {
+			for _, step := range entry.Steps {
+				fmt.Println("\t\t" + Colorize(StatusColor(step.Status), "["+step.Status+"]") + " " + step.Manager + ": " + step.Command)
+			}
+		}
+	}
+	return 0
+}
+
+// Method to find the color of a run result
+func HistoryColor(result string) string {
+	switch result {
+	case RESULT_OK:
+		return COLOR_GREEN
+	case RESULT_FAILED:
+		return COLOR_RED
+	default:
+		return COLOR_YELLOW
+	}
+}
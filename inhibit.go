@@ -0,0 +1,118 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Inhibiting sleep and shutdown through systemd-logind while updating
+
+package main
+
+// Import packages
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// // What is inhibited while official package managers run
+const INHIBIT_WHAT string = "sleep:shutdown:idle"
+
+// // Time given to systemd-inhibit to fail, e.g. without logind or when denied by polkit
+const INHIBIT_STARTUP_GRACE time.Duration = 500 * time.Millisecond
+
+// A held inhibitor lock, released by closing the input of the inhibiting command
+type Inhibitor struct {
+	input  io.WriteCloser
+	exited chan error
+}
+
+// Method to check if systemd-inhibit may ask for a password, and so takes --no-ask-password
+//
+// Versions before systemd 257 neither ask, nor accept the option.
+func InhibitAsksPassword(inhibitPath string) bool {
+	stdout, _ := exec.Command(inhibitPath, "--help").Output()
+	return strings.Contains(string(stdout), "--no-ask-password")
+}
+
+// Method to take a block inhibitor lock for sleep, shutdown and idle, if logind is available
+//
+// systemd-inhibit holds the lock while cat waits for input from update_full, so the lock is
+// released when Release is called, or when update_full dies and the pipe closes.
+// Returns nil (after a warning) where the lock cannot be taken.
+func InhibitSleep(why string) *Inhibitor {
+	// Initialise variables
+	var stderr bytes.Buffer
+	if OS_TYPE != "linux" {
+		return nil
+	}
+
+	elevated := os.Geteuid() == 0
+	inhibitPath, err := ResolveManager("systemd-inhibit", elevated, nil)
+	if err != nil {
+		PrintWarning("!!Could NOT inhibit sleep and shutdown (systemd-inhibit not found), keep the system awake until updates finish")
+		logger.Warn("sleep not inhibited", "err", err)
+		return nil
+	}
+	catPath, err := ResolveManager("cat", elevated, nil)
+	if err != nil {
+		logger.Warn("sleep not inhibited", "err", err)
+		return nil
+	}
+
+	// Polkit must not prompt for a password, which would hang the run or steal the terminal
+	args := []string{"--what=" + INHIBIT_WHAT, "--who=update_full", "--why=" + why, "--mode=block", catPath}
+	if InhibitAsksPassword(inhibitPath) {
+		args = append([]string{"--no-ask-password"}, args...)
+	}
+	command := exec.Command(inhibitPath, args...)
+	command.Stderr = &stderr
+	// Keep Ctrl-C from releasing the lock before the current step finishes
+	DetachFromTerminal(command)
+	input, err := command.StdinPipe()
+	if err == nil {
+		err = command.Start()
+	}
+	if err != nil {
+		PrintWarning("!!Could NOT inhibit sleep and shutdown:", err)
+		logger.Warn("sleep not inhibited", "err", err)
+		return nil
+	}
+
+	// systemd-inhibit exits at once if logind is missing, or the lock is denied
+	exited := make(chan error, 1)
+	go func() { exited <- command.Wait() }()
+	select {
+	case err = <-exited:
+		if err == nil {
+			err = errors.New("exited early")
+		}
+		if detail := strings.TrimSpace(stderr.String()); detail != "" {
+			err = errors.New(detail)
+		}
+		PrintWarning("!!Could NOT inhibit sleep and shutdown (" + err.Error() + "), keep the system awake until updates finish")
+		logger.Warn("sleep not inhibited", "err", err)
+		return nil
+	case <-time.After(INHIBIT_STARTUP_GRACE):
+	}
+
+	PrintVerbose("\t* Inhibiting " + strings.ReplaceAll(INHIBIT_WHAT, ":", ", ") + " until updates finish")
+	logger.Info("sleep inhibited", "what", INHIBIT_WHAT, "why", why)
+	return &Inhibitor{input: input, exited: exited}
+}
+
+// Method to release an inhibitor lock, if held
+func (inhibitor *Inhibitor) Release() {
+	if inhibitor == nil {
+		return
+	}
+	inhibitor.input.Close()
+	// Wait for systemd-inhibit to exit, so the lock is gone before rebooting
+	select {
+	case err := <-inhibitor.exited:
+		logger.Debug("sleep inhibitor released", "err", err)
+	case <-time.After(INHIBIT_STARTUP_GRACE):
+		logger.Warn("sleep inhibitor did not exit")
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !linux && !darwin && !freebsd && !openbsd
+
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Checks of package manager locks, on systems without fcntl locks
+
+package main
+
+// Import packages
+import (
+	"errors"
+)
+
+// Method to check if another process holds the lock of a file (unsupported here)
+func LockHolder(path string) (bool, int, error) {
+	return false, 0, errors.New("lock checks are not supported on " + OS_TYPE)
+}
+
+// Method to check if a process is still running (unsupported here, so assumed running)
+func ProcessAlive(pid int) bool {
+	return true
+}
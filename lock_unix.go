@@ -0,0 +1,49 @@
+//go:build linux || darwin || freebsd || openbsd
+
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// UNIX-specific checks of package manager locks
+
+package main
+
+// Import packages
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// Method to check if another process holds the fcntl lock of a file, without taking it
+//
+// Returns whether the lock is held, and the holding process (-1 if unknown, e.g. for OFD locks).
+func LockHolder(path string) (bool, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, 0, err
+	}
+	defer file.Close()
+
+	lock := syscall.Flock_t{Type: syscall.F_WRLCK, Whence: io.SeekStart}
+	if err = syscall.FcntlFlock(file.Fd(), syscall.F_GETLK, &lock); err != nil {
+		return false, 0, err
+	}
+	if lock.Type == syscall.F_UNLCK {
+		return false, 0, nil
+	}
+	pid := int(lock.Pid)
+	if pid <= 0 {
+		pid = -1
+	}
+	return true, pid, nil
+}
+
+// Method to check if a process is still running
+func ProcessAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,92 @@
+//go:build linux || darwin || freebsd || openbsd
+
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Tests of package manager lock checks, with the lock held by a helper process
+
+package main
+
+// Import packages
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// // Lock file the helper process locks, set only in the helper process
+const LOCK_HELPER_ENV string = "UPDATE_FULL_TEST_LOCK_HELPER"
+
+// Helper process: takes the fcntl lock of a file, and holds it until its input closes
+//
+// fcntl locks are held per process, so the process checking a lock can not hold it itself.
+func TestLockHelperProcess(t *testing.T) {
+	path := os.Getenv(LOCK_HELPER_ENV)
+	if path == "" {
+		return
+	}
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		os.Exit(1)
+	}
+	lock := syscall.Flock_t{Type: syscall.F_WRLCK, Whence: io.SeekStart}
+	if err = syscall.FcntlFlock(file.Fd(), syscall.F_SETLK, &lock); err != nil {
+		os.Exit(1)
+	}
+	os.Stdout.WriteString("locked\n")
+	io.Copy(io.Discard, os.Stdin)
+	os.Exit(0)
+}
+
+func TestLockHolder(t *testing.T) {
+	// Initialise variables
+	path := filepath.Join(t.TempDir(), "lock")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Free lock
+	if held, _, err := LockHolder(path); err != nil || held {
+		t.Fatalf("free lock = held %v, error %v", held, err)
+	}
+
+	// Lock held by the helper process
+	helper := exec.Command(os.Args[0], "-test.run=^TestLockHelperProcess$")
+	helper.Env = append(os.Environ(), LOCK_HELPER_ENV+"="+path)
+	input, err := helper.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := helper.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = helper.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer helper.Wait()
+	defer input.Close()
+	if line, err := bufio.NewReader(output).ReadString('\n'); err != nil || line != "locked\n" {
+		t.Fatalf("helper did not lock: %q, %v", line, err)
+	}
+	held, pid, err := LockHolder(path)
+	if err != nil || !held || pid != helper.Process.Pid {
+		t.Errorf("held lock = held %v by %d, error %v, want held by %d", held, pid, err, helper.Process.Pid)
+	}
+
+	// Released when the helper exits
+	input.Close()
+	helper.Wait()
+	if held, _, err := LockHolder(path); err != nil || held {
+		t.Errorf("released lock = held %v, error %v", held, err)
+	}
+
+	// Missing lock file
+	if _, _, err := LockHolder(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("missing lock file checked without error")
+	}
+}
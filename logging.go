@@ -0,0 +1,128 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Structured, leveled logging
+
+package main
+
+// Import packages
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// // Logging settings, set by flags
+var logLevel string
+var logFormat string = "text"
+var logFile string
+
+// // Logger used across update_full, discarding everything until SetupLogging is called
+var logger *slog.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+var logSink *os.File
+
+// // Level accepted by --log-level to turn logging off
+const LOG_LEVEL_OFF string = "off"
+
+// Method to find the effective log level
+//
+// Without --log-level, logging is at debug level with -d, info level with --log-file, and off otherwise.
+func EffectiveLogLevel(level string, debug bool, file string) string {
+	switch {
+	case level != "":
+		return strings.ToLower(level)
+	case debug:
+		return "debug"
+	case file != "":
+		return "info"
+	default:
+		return LOG_LEVEL_OFF
+	}
+}
+
+// Method to list the values accepted by --log-level and --log-format, for shell completion
+func LogLevelChoices(config *Config) []string {
+	return []string{"debug", "info", "warn", "error", LOG_LEVEL_OFF}
+}
+func LogFormatChoices(config *Config) []string {
+	return []string{"text", "json"}
+}
+
+// Method to parse a log level name
+func ParseLogLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, errors.New("invalid log level [" + level + "] (debug, info, warn, error or off)")
+}
+
+// Method to redact secrets from every logged string, error and message
+func RedactAttr(groups []string, attr slog.Attr) slog.Attr {
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, RedactSecrets(attr.Value.String()))
+	case slog.KindAny:
+		switch value := attr.Value.Any().(type) {
+		case error:
+			return slog.String(attr.Key, RedactSecrets(value.Error()))
+		case []string:
+			return slog.String(attr.Key, RedactSecrets(strings.Join(value, " ")))
+		case fmt.Stringer:
+			return slog.String(attr.Key, RedactSecrets(value.String()))
+		}
+	}
+	return attr
+}
+
+// Method to set up the logger, writing to stderr, or to a file if given
+func SetupLogging(level string, format string, file string) error {
+	// Initialise variables
+	var output io.Writer = os.Stderr
+
+	if level == LOG_LEVEL_OFF {
+		return nil
+	}
+	parsedLevel, err := ParseLogLevel(level)
+	if err != nil {
+		return err
+	}
+
+	// Open the log file, appending to earlier runs
+	if file != "" {
+		logSink, err = os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return err
+		}
+		output = logSink
+	}
+
+	options := &slog.HandlerOptions{Level: parsedLevel, ReplaceAttr: RedactAttr}
+	switch strings.ToLower(format) {
+	case "text":
+		logger = slog.New(slog.NewTextHandler(output, options))
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(output, options))
+	default:
+		CloseLogging()
+		return errors.New("invalid log format [" + format + "] (text or json)")
+	}
+	return nil
+}
+
+// Method to close the log file, if any
+func CloseLogging() {
+	if logSink != nil {
+		logSink.Close()
+		logSink = nil
+	}
+}
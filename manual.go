@@ -0,0 +1,61 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Manual mode: confirming every package manager step
+
+package main
+
+// Import packages
+import (
+	"fmt"
+	"strings"
+)
+
+// // Possible decisions of the USER on a planned step
+const DECISION_RUN string = "run"
+const DECISION_SKIP string = "skip"
+const DECISION_ABORT string = "abort"
+
+// Method to ask the USER whether to run, skip or edit a planned step, or abort the run
+//
+// Returns the decision, and the (possibly edited) arguments of the package manager.
+func ConfirmStep(label string, manager string, escalation string, args []string) (string, []string) {
+	// Show the planned command
+	fmt.Println()
+	fmt.Print("* Planned step for [" + label + "]: " + RedactSecrets(strings.Join(append([]string{manager}, args...), " ")))
+	if escalation != "" {
+		fmt.Print(" (through " + escalation + ")")
+	}
+	fmt.Println()
+
+	for {
+		answer, err := PromptLine("  [r]un / [s]kip / [e]dit arguments / [a]bort? ")
+		// End of input can not approve anything
+		if err != nil {
+			fmt.Println()
+			return DECISION_ABORT, args
+		}
+		switch strings.ToLower(answer) {
+		case "r", "run", "y", "yes":
+			return DECISION_RUN, args
+		case "s", "skip", "n", "no":
+			return DECISION_SKIP, args
+		case "a", "abort", "q", "quit":
+			return DECISION_ABORT, args
+		case "e", "edit":
+			line, err := PromptLine("  New arguments for [" + manager + "]: ")
+			if err != nil {
+				continue
+			}
+			edited, err := SplitArgs(line)
+			if err != nil {
+				fmt.Println("!!", err)
+				continue
+			}
+			args = edited
+			fmt.Println("* Edited step: " + RedactSecrets(strings.Join(append([]string{manager}, args...), " ")))
+		default:
+			fmt.Println("!!Please answer r, s, e or a")
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Options keeping each package manager step from prompting in unattended runs
+
+package main
+
+// // Options keeping existing configuration files when Dpkg would ask about them
+var DPKG_CONFFILE_OPTIONS []string = []string{"-o", "Dpkg::Options::=--force-confdef", "-o", "Dpkg::Options::=--force-confold"}
+
+// Method to find the assume-yes options of a package manager step
+//
+// Returns options placed before the step's actions (right after the package manager),
+// options placed after them, and environment variables.
+func NonInteractiveArgs(pkgNum int, official bool, step int) ([]string, []string, []string) {
+	switch official {
+	// Official package managers
+	case true:
+		switch pkgNum {
+		// Apt package manager: update needs nothing, dpkg must not ask about conffiles
+		case 0:
+			env := []string{"DEBIAN_FRONTEND=noninteractive", "NEEDRESTART_MODE=a"}
+			switch step {
+			case 0:
+				return nil, nil, env
+			case 1, 2, 3:
+				return nil, append([]string{"-y"}, DPKG_CONFFILE_OPTIONS...), env
+			default:
+				return nil, []string{"-y"}, env
+			}
+		// Dnf & Yum package manager: check-update needs nothing
+		case 1, 4:
+			switch step {
+			case 0:
+				return nil, nil, nil
+			default:
+				return nil, []string{"-y"}, nil
+			}
+		// OpenSUSE immutable
+		case 2:
+			return []string{"--non-interactive"}, nil, nil
+		// Zypper package manager: global option, before the subcommand
+		case 3:
+			switch step {
+			case 2, 3:
+				return []string{"--non-interactive"}, []string{"--auto-agree-with-licenses"}, nil
+			default:
+				return []string{"--non-interactive"}, nil, nil
+			}
+		// Clear Linux: update
+		case 7:
+			switch step {
+			case 1:
+				return nil, []string{"--assume=yes"}, nil
+			}
+		// Arch Linux
+		case 8:
+			return nil, []string{"--noconfirm"}, nil
+		// OpenBSD
+		case 9:
+			return nil, []string{"-I"}, nil
+		// FreeBSD: upgrade, autoremove, clean
+		case 10:
+			env := []string{"ASSUME_ALWAYS_YES=yes"}
+			switch step {
+			case 1, 2, 3:
+				return nil, []string{"-y"}, env
+			default:
+				return nil, nil, env
+			}
+		// Solus Linux: upgrade
+		case 11:
+			switch step {
+			case 1:
+				return nil, []string{"-y"}, nil
+			}
+		// Slackware Linux: options before the action
+		case 12:
+			return []string{"-batch=on", "-default_answer=y"}, nil, nil
+		// Winget
+		case 14:
+			return nil, []string{"--accept-source-agreements", "--accept-package-agreements", "--disable-interactivity"}, nil
+		}
+	// Alternative package managers
+	case false:
+		switch pkgNum {
+		// Brew package manager
+		case 0:
+			return nil, nil, []string{"NONINTERACTIVE=1"}
+		// Chocolatey package manager
+		case 2:
+			return nil, []string{"-y"}, nil
+		// Flatpak package manager: update, uninstall --unused
+		case 3:
+			switch step {
+			case 0:
+				return nil, []string{"--noninteractive"}, nil
+			default:
+				return nil, []string{"-y"}, nil
+			}
+		}
+	}
+
+	// Otherwise, the step does not prompt
+	return nil, nil, nil
+}
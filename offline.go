@@ -0,0 +1,65 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Offline mode, running package managers from their local caches only
+
+package main
+
+// // Offline setting, set by flags
+var offlineFlag bool
+
+// Method to find the options making a package manager step work from its local cache
+//
+// Returns options placed before and after the step's actions, and whether the step
+// can run offline at all. Network-bound steps without a cache-only form are skipped.
+func OfflineArgs(pkgNum int, official bool, step int) ([]string, []string, bool) {
+	switch official {
+	// Official package managers
+	case true:
+		switch pkgNum {
+		// Apt package manager: dist-upgrade, -f install
+		case 0:
+			switch step {
+			case 1, 2:
+				return nil, []string{"--no-download"}, true
+			}
+		// Dnf & Yum package manager
+		case 1, 4:
+			return []string{"-C"}, nil, true
+		// Zypper package manager
+		case 3:
+			return []string{"--no-refresh"}, nil, true
+		// Rpm-Ostree: upgrade --check, upgrade
+		case 5:
+			switch step {
+			case 1, 2:
+				return nil, []string{"--cache-only"}, true
+			}
+		// Apk: upgrade, fix
+		case 6:
+			switch step {
+			case 1, 2:
+				return nil, []string{"--no-network"}, true
+			}
+		// FreeBSD: upgrade without updating the repository catalogue
+		case 10:
+			switch step {
+			case 1:
+				return nil, []string{"-U"}, true
+			}
+		}
+	// Alternative package managers
+	case false:
+		switch pkgNum {
+		// Flatpak package manager: update
+		case 3:
+			switch step {
+			case 0:
+				return nil, []string{"--no-pull"}, true
+			}
+		}
+	}
+
+	// Otherwise, only steps not needing the network may run
+	return nil, nil, !IsNetworkStep(pkgNum, official, step)
+}
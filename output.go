@@ -0,0 +1,88 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Terminal output: quiet, verbose and colorized status lines
+
+package main
+
+// Import packages
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// // Output settings, set by flags
+var quietFlag bool
+var verboseFlag bool
+var noBannerFlag bool
+var colorOutput bool
+
+// // ANSI colors of status lines
+const COLOR_RESET string = "\033[0m"
+const COLOR_RED string = "\033[31m"
+const COLOR_GREEN string = "\033[32m"
+const COLOR_YELLOW string = "\033[33m"
+const COLOR_DIM string = "\033[2m"
+
+// Method to check if colors should be used: only on terminals, and never with NO_COLOR set
+func ColorSupported() bool {
+	if _, found := os.LookupEnv("NO_COLOR"); found {
+		return false
+	}
+	return IsTerminal(os.Stdout) && os.Getenv("TERM") != "dumb"
+}
+
+// Method to wrap text in a color, if colors are used
+func Colorize(color string, text string) string {
+	switch colorOutput {
+	case true:
+		return color + text + COLOR_RESET
+	}
+	return text
+}
+
+// Method to join arguments the way fmt.Println does, without the newline
+func JoinOutput(args ...any) string {
+	return strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+}
+
+// Method to print a status line, unless quiet
+func PrintStatus(args ...any) {
+	if !quietFlag {
+		fmt.Println(JoinOutput(args...))
+	}
+}
+
+// Method to print a successful status line, unless quiet
+func PrintSuccess(args ...any) {
+	if !quietFlag {
+		fmt.Println(Colorize(COLOR_GREEN, JoinOutput(args...)))
+	}
+}
+
+// Method to print a warning, unless quiet
+func PrintWarning(args ...any) {
+	if !quietFlag {
+		fmt.Println(Colorize(COLOR_YELLOW, JoinOutput(args...)))
+	}
+}
+
+// Method to print a failure, always
+func PrintFailure(args ...any) {
+	fmt.Println(Colorize(COLOR_RED, JoinOutput(args...)))
+}
+
+// Method to print extra details, only if verbose
+func PrintVerbose(args ...any) {
+	if verboseFlag && !quietFlag {
+		fmt.Println(Colorize(COLOR_DIM, JoinOutput(args...)))
+	}
+}
+
+// Method to print the output of a package manager, unless quiet
+func PrintOutput(output []byte) {
+	if !quietFlag && len(output) > 0 {
+		fmt.Println(string(output))
+	}
+}
@@ -0,0 +1,115 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Resolution of package managers to trusted paths, and minimal environments
+
+package main
+
+// Import packages
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// // System directories trusted to hold binaries run with ROOT privileges
+var TRUSTED_PATH_DIRS []string = []string{"/usr/local/sbin", "/usr/local/bin", "/usr/sbin", "/usr/bin", "/sbin", "/bin"}
+
+// // Usual locations of user-level package managers missing from root's PATH
+var USER_MANAGER_DIRS []string = []string{"/home/linuxbrew/.linuxbrew/bin", "/opt/homebrew/bin", "/usr/local/bin"}
+
+// // Check of binaries run with ROOT privileges, replaced by tests using fake binaries
+var checkTrustedBinary func(path string) error = CheckTrustedBinary
+
+// // Variables kept from the current environment when running elevated commands
+var KEPT_ENV_VARS []string = []string{"LANG", "LANGUAGE", "LC_ALL", "TERM"}
+
+// Method to build the PATH given to elevated commands
+func TrustedPath() string {
+	return strings.Join(TRUSTED_PATH_DIRS, string(os.PathListSeparator))
+}
+
+// Method to build a minimal, explicit environment for elevated commands
+func MinimalEnv(home string, extra []string) []string {
+	// Initialise variables
+	env := []string{"PATH=" + TrustedPath(), "HOME=" + home}
+	for _, name := range KEPT_ENV_VARS {
+		if value, found := os.LookupEnv(name); found {
+			env = append(env, name+"="+value)
+		}
+	}
+	return append(env, extra...)
+}
+
+// Method to find the home directory of root (e.g. /var/root on macOS)
+func RootHome() string {
+	if root, err := user.LookupId("0"); err == nil && root.HomeDir != "" {
+		return root.HomeDir
+	}
+	return "/root"
+}
+
+// Method to resolve a helper binary run by update_full itself, with the options to run it
+//
+// Running as root, the helper must be trusted and gets a minimal environment, as elevated
+// package managers do.
+func ResolveHelper(name string, env []string) (string, CommandOptions, error) {
+	if OS_TYPE == "windows" || os.Geteuid() != 0 {
+		path, err := ResolveManager(name, false, nil)
+		return path, CommandOptions{Env: env}, err
+	}
+	path, err := ResolveManager(name, true, nil)
+	return path, CommandOptions{Env: MinimalEnv(RootHome(), env), CleanEnv: true}, err
+}
+
+// Method to find an executable file in the given directories
+func FindInDirs(name string, dirs []string) (string, error) {
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() && info.Mode()&0o111 != 0 {
+			return path, nil
+		}
+	}
+	return "", errors.New("[" + name + "] not found in " + strings.Join(dirs, ":"))
+}
+
+// Method to resolve a package manager (or helper binary) to an absolute path
+//
+// Binaries run with ROOT privileges must be found in TRUSTED_PATH_DIRS, and neither
+// they nor their directories may be writable by other users. User-level binaries are
+// looked up through PATH, falling back to their usual locations.
+func ResolveManager(name string, elevated bool, account *user.User) (string, error) {
+	// Windows has no escalation, so only resolve through PATH
+	if OS_TYPE == "windows" {
+		return exec.LookPath(name)
+	}
+
+	switch elevated {
+	case true:
+		path, err := FindInDirs(name, TRUSTED_PATH_DIRS)
+		if err != nil {
+			return "", err
+		}
+		// Check the file actually run, after following symlinks
+		realPath, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return "", err
+		}
+		if err = checkTrustedBinary(realPath); err != nil {
+			return "", err
+		}
+		return path, nil
+	default:
+		if path, err := exec.LookPath(name); err == nil {
+			return filepath.Abs(path)
+		}
+		dirs := USER_MANAGER_DIRS
+		if account != nil {
+			dirs = append([]string{filepath.Join(account.HomeDir, ".linuxbrew", "bin")}, dirs...)
+		}
+		return FindInDirs(name, dirs)
+	}
+}
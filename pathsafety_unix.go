@@ -0,0 +1,46 @@
+//go:build !windows
+
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// UNIX-specific checks of binaries run with ROOT privileges
+
+package main
+
+// Import packages
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Method to check that a path is owned by root and not writable by other users
+func CheckTrustedFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if ok && stat.Uid != 0 {
+		return errors.New("[" + path + "] is not owned by root")
+	}
+	if info.Mode().Perm()&0o022 != 0 {
+		return errors.New("[" + path + "] is writable by other users")
+	}
+	return nil
+}
+
+// Method to check a binary and every directory above it
+func CheckTrustedBinary(path string) error {
+	for {
+		if err := CheckTrustedFile(path); err != nil {
+			return err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return nil
+		}
+		path = parent
+	}
+}
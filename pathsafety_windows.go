@@ -0,0 +1,13 @@
+//go:build windows
+
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Windows-specific checks of binaries run with administrator privileges
+
+package main
+
+// Method to check a binary and every directory above it (no escalation on Windows yet)
+func CheckTrustedBinary(path string) error {
+	return nil
+}
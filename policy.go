@@ -0,0 +1,265 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Update policies: managers to use, security-only updates, hooks and reboots
+
+package main
+
+// Import packages
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// // Reboot policies
+const REBOOT_NEVER string = "never"
+const REBOOT_IF_REQUIRED string = "if-required"
+const REBOOT_ALWAYS string = "always"
+
+// // Phases of hooks
+const HOOK_PRE string = "pre"
+const HOOK_POST string = "post"
+
+// // Files created by package managers when a reboot is required
+var REBOOT_REQUIRED_FILES []string = []string{"/var/run/reboot-required", "/run/reboot-required"}
+
+// // Policy settings, set by flags, configuration or profiles
+var managersFilter []string
+var securityOnlyFlag bool
+var rebootPolicy string = REBOOT_NEVER
+var preHooks []string
+var postHooks []string
+var stepTimeout time.Duration
+
+// Method to find the name of a package manager
+func PkgManagerName(pkgNum int, official bool) string {
+	switch official {
+	case true:
+		return OFFICIAL_PKG_MANAGERS[pkgNum]
+	default:
+		return ALTERNATIVE_PKG_MANAGERS[pkgNum]
+	}
+}
+
+// Method to list the package managers chosen through --managers (which may hold comma-separated names)
+func ChosenManagers() []string {
+	// Initialise variables
+	var names []string
+	for _, value := range managersFilter {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// Method to check the names given through --managers
+func ValidateManagers() error {
+	for _, name := range ChosenManagers() {
+		known := false
+		for _, manager := range append(OFFICIAL_PKG_MANAGERS[:], ALTERNATIVE_PKG_MANAGERS[:]...) {
+			known = known || strings.EqualFold(name, manager)
+		}
+		if !known {
+			return errors.New("unknown package manager [" + name + "]")
+		}
+	}
+	return nil
+}
+
+// Method to list every package manager name accepted by --managers, for shell completion
+func ManagerChoices(config *Config) []string {
+	return append(OFFICIAL_PKG_MANAGERS[:], ALTERNATIVE_PKG_MANAGERS[:]...)
+}
+
+// Method to check if a package manager was chosen (every one is, without --managers)
+func ManagerChosen(pkgNum int, official bool) bool {
+	names := ChosenManagers()
+	for _, name := range names {
+		if strings.EqualFold(name, PkgManagerName(pkgNum, official)) {
+			return true
+		}
+	}
+	return len(names) == 0
+}
+
+// Method to find the options limiting a step to security updates
+//
+// Returns options added after the step's actions, whether the step runs at all, and whether
+// the package manager can tell security updates apart.
+func SecurityArgs(pkgNum int, official bool, step int) ([]string, bool, bool) {
+	switch official {
+	// Official package managers
+	case true:
+		switch pkgNum {
+		// Dnf & Yum package manager: check-update, update
+		case 1, 4:
+			switch step {
+			case 0, 1:
+				return []string{"--security"}, true, true
+			}
+			return nil, true, true
+		// Zypper package manager: security patches replace the full update
+		case 3:
+			switch step {
+			case 2:
+				return nil, false, true
+			case 3:
+				return []string{"--category", "security"}, true, true
+			}
+			return nil, true, true
+		}
+	}
+	return nil, true, false
+}
+
+// Method to check if a package manager is left out by the policy, and why
+func ManagerPolicySkip(pkgNum int, official bool) (bool, string) {
+	if !ManagerChosen(pkgNum, official) {
+		return true, "not in --managers"
+	}
+	if _, _, supported := SecurityArgs(pkgNum, official, 0); securityOnlyFlag && !supported {
+		return true, "cannot limit updates to security fixes"
+	}
+	return false, ""
+}
+
+// Method to validate a reboot policy
+func ValidateRebootPolicy(policy string) bool {
+	switch policy {
+	case REBOOT_NEVER, REBOOT_IF_REQUIRED, REBOOT_ALWAYS:
+		return true
+	}
+	return false
+}
+
+// Method to list the reboot policies, for shell completion
+func RebootChoices(config *Config) []string {
+	return []string{REBOOT_NEVER, REBOOT_IF_REQUIRED, REBOOT_ALWAYS}
+}
+
+// Method to run hooks of a phase through the shell, recording them in the report
+//
+// Hooks run as the user running update_full, and stop at the first failure. Running as
+// root, they get the trusted PATH and a minimal environment, as package managers do.
+func RunHooks(phase string, hooks []string, env []string) error {
+	for _, hook := range hooks {
+		// Initialise variables
+		var shell []string = []string{"sh", "-c", hook}
+		if OS_TYPE == "windows" {
+			shell = []string{"cmd", "/C", hook}
+		}
+		label := "hook (" + phase + ")"
+		hookEnv := append([]string{"UPDATE_FULL_PHASE=" + phase, "UPDATE_FULL_PROFILE=" + activeProfile}, env...)
+
+		PrintStatus("* Running " + phase + "-update hook [" + hook + "]")
+		hookBegin := time.Now()
+		path, options, err := ResolveHelper(shell[0], hookEnv)
+		if err != nil {
+			PrintFailure("!!Hook ["+hook+"] failed:", err)
+			runReport.AddStep(label, shell, STEP_FAILED, err, 0)
+			return errors.New(phase + "-update hook [" + hook + "] failed: " + err.Error())
+		}
+		options.Timeout = stepTimeout
+		stdout, stderr, err := RunCommand(options, path, shell[1:]...)
+		PrintOutput(stdout)
+		logger.Info("hook finished", "phase", phase, "command", hook, "duration", time.Since(hookBegin), "err", err)
+		if err != nil {
+			PrintFailure("!!Hook ["+hook+"] failed:", err)
+			if len(stderr) > 0 {
+				PrintFailure(strings.TrimSpace(string(stderr)))
+			}
+			runReport.AddStep(label, shell, STEP_FAILED, err, time.Since(hookBegin))
+			return errors.New(phase + "-update hook [" + hook + "] failed: " + err.Error())
+		}
+		runReport.AddStep(label, shell, STEP_OK, nil, time.Since(hookBegin))
+	}
+	return nil
+}
+
+// Method to check if the system asks for a reboot after updating
+func RebootRequired() bool {
+	for _, path := range REBOOT_REQUIRED_FILES {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	// needs-restarting (dnf/yum) exits with 1 when a reboot is needed
+	if path, err := exec.LookPath("needs-restarting"); err == nil {
+		var exitErr *exec.ExitError
+		if err = exec.Command(path, "-r").Run(); errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return true
+		}
+	}
+	// zypper exits with 102 when a reboot is needed
+	if path, err := exec.LookPath("zypper"); err == nil {
+		var exitErr *exec.ExitError
+		if err = exec.Command(path, "needs-rebooting").Run(); errors.As(err, &exitErr) && exitErr.ExitCode() == 102 {
+			return true
+		}
+	}
+	return false
+}
+
+// Method to schedule a reboot in a minute, following the reboot policy
+//
+// Runs without failed steps only, and never for cancelled or user-only runs.
+func RebootIfNeeded() {
+	// Initialise variables
+	var name string
+	var args []string
+
+	switch {
+	case rebootPolicy == REBOOT_NEVER:
+		return
+	case IsCancelled() || runReport.Failed():
+		PrintWarning("* Not rebooting, as the run did not complete successfully")
+		runReport.AddNote("Reboot skipped: run did not complete successfully")
+		return
+	case userOnlyFlag:
+		PrintWarning("* Not rebooting, as the run was user-only")
+		runReport.AddNote("Reboot skipped: user-only run")
+		return
+	case rebootPolicy == REBOOT_IF_REQUIRED && !RebootRequired():
+		PrintStatus("* No reboot required")
+		return
+	}
+
+	// Find the command, through sudo/doas if escalating
+	switch OS_TYPE {
+	case "windows":
+		name, args = "shutdown", []string{"/r", "/t", "60"}
+	default:
+		path, err := ResolveManager("shutdown", true, nil)
+		if err != nil {
+			PrintFailure("!!Could NOT schedule reboot:", err)
+			runReport.AddStep("reboot", []string{"shutdown"}, STEP_FAILED, err, 0)
+			return
+		}
+		name, args = path, []string{"-r", "+1", "update_full: rebooting after updates"}
+		if rootUse != "" {
+			escalationPath, err := ResolveManager(rootUse, true, nil)
+			if err != nil {
+				PrintFailure("!!Could NOT schedule reboot:", err)
+				runReport.AddStep("reboot", []string{rootUse, path}, STEP_FAILED, err, 0)
+				return
+			}
+			name, args = escalationPath, append([]string{path}, args...)
+		}
+	}
+
+	PrintWarning("* Rebooting in one minute (--reboot=" + rebootPolicy + ")")
+	_, _, err := RunCommand(CommandOptions{}, name, args...)
+	switch err {
+	case nil:
+		runReport.AddStep("reboot", append([]string{name}, args...), STEP_OK, nil, 0)
+	default:
+		PrintFailure("!!Could NOT schedule reboot:", err)
+		runReport.AddStep("reboot", append([]string{name}, args...), STEP_FAILED, err, 0)
+	}
+}
@@ -0,0 +1,140 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Power state: refusing or postponing updates on a low battery
+
+package main
+
+// Import packages
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// // Power supplies, under the sysfs root
+const POWER_SUPPLY_DIR string = "class/power_supply"
+
+// // Interval between checks while postponing for power
+const POWER_POLL_INTERVAL time.Duration = 30 * time.Second
+
+// // Power settings, set by flags
+var minBattery int = 30
+var ignoreBatteryFlag bool
+var batteryWait time.Duration
+var sysfsRoot string = "/sys"
+
+// Power state of the system, as read from sysfs
+type PowerState struct {
+	OnAC      bool
+	Batteries int
+	// Average charge of the system's batteries, in percent
+	Capacity int
+}
+
+// Method to read a sysfs attribute, trimmed
+func ReadSysfsValue(dir string, name string) string {
+	content, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// Method to read the power state from the power supplies under a sysfs root
+//
+// Batteries of peripherals (scope Device, e.g. wireless mice) are ignored, and a charging
+// battery counts as being on AC.
+func ReadPowerState(root string) (PowerState, error) {
+	// Initialise variables
+	var state PowerState
+	var total int
+
+	supplies, err := os.ReadDir(filepath.Join(root, POWER_SUPPLY_DIR))
+	if err != nil {
+		return state, err
+	}
+	for _, supply := range supplies {
+		dir := filepath.Join(root, POWER_SUPPLY_DIR, supply.Name())
+		switch ReadSysfsValue(dir, "type") {
+		case "Mains", "USB", "USB_C", "USB_PD":
+			if ReadSysfsValue(dir, "online") == "1" {
+				state.OnAC = true
+			}
+		case "Battery":
+			if ReadSysfsValue(dir, "scope") == "Device" || ReadSysfsValue(dir, "present") == "0" {
+				continue
+			}
+			capacity, err := strconv.Atoi(ReadSysfsValue(dir, "capacity"))
+			if err != nil {
+				logger.Debug("battery capacity unreadable", "supply", supply.Name(), "err", err)
+				continue
+			}
+			if ReadSysfsValue(dir, "status") == "Charging" {
+				state.OnAC = true
+			}
+			state.Batteries++
+			total += capacity
+		}
+	}
+	if state.Batteries > 0 {
+		state.Capacity = total / state.Batteries
+	}
+	return state, nil
+}
+
+// Method to check if the power state allows updating
+func PowerSufficient(state PowerState) bool {
+	return state.OnAC || state.Batteries == 0 || state.Capacity >= minBattery
+}
+
+// Method to describe the power state, e.g. "on battery at 12%"
+func (state PowerState) String() string {
+	switch {
+	case state.Batteries == 0:
+		return "no battery"
+	case state.OnAC:
+		return "on AC, battery at " + strconv.Itoa(state.Capacity) + "%"
+	default:
+		return "on battery at " + strconv.Itoa(state.Capacity) + "%"
+	}
+}
+
+// Method to check the battery before updating, postponing for up to --battery-wait
+//
+// Returns an error if the run should not go ahead.
+func PowerPreflight() error {
+	if ignoreBatteryFlag || minBattery <= 0 {
+		return nil
+	}
+	state, err := ReadPowerState(sysfsRoot)
+	if err != nil {
+		// Systems without sysfs (or power supplies) are assumed to be on mains
+		logger.Debug("power state unavailable", "root", sysfsRoot, "err", err)
+		return nil
+	}
+	logger.Info("power state", "on_ac", state.OnAC, "batteries", state.Batteries, "capacity", state.Capacity)
+	if PowerSufficient(state) {
+		return nil
+	}
+
+	// Wait for AC or a charged battery, if allowed
+	deadline := time.Now().Add(batteryWait)
+	if batteryWait > 0 {
+		PrintWarning("!!Battery below " + strconv.Itoa(minBattery) + "% (" + state.String() + "), waiting up to " + batteryWait.String() + " for AC power...")
+	}
+	for time.Now().Before(deadline) {
+		if !CancellableSleep(min(POWER_POLL_INTERVAL, time.Until(deadline))) {
+			return nil
+		}
+		if state, err = ReadPowerState(sysfsRoot); err != nil || PowerSufficient(state) {
+			PrintStatus("* Power restored (" + state.String() + "), continuing")
+			runReport.AddNote("Run postponed until power was restored (" + state.String() + ")")
+			return nil
+		}
+	}
+	return errors.New("battery below " + strconv.Itoa(minBattery) + "% (" + state.String() + "), connect AC power or use --ignore-battery")
+}
@@ -0,0 +1,89 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Tests of the power state, against power supplies in a temporary sysfs root
+
+package main
+
+// Import packages
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Method to describe a battery as sysfs attribute files
+func BatterySupply(dir string, capacity string, status string) map[string]string {
+	return map[string]string{
+		dir + "/type":     "Battery\n",
+		dir + "/present":  "1\n",
+		dir + "/capacity": capacity + "\n",
+		dir + "/status":   status + "\n",
+	}
+}
+
+// Method to merge sysfs attribute files of several power supplies
+func PowerSupplies(supplies ...map[string]string) map[string]string {
+	// Initialise variables
+	files := map[string]string{}
+	for _, supply := range supplies {
+		for name, content := range supply {
+			files[filepath.Join(POWER_SUPPLY_DIR, name)] = content
+		}
+	}
+	return files
+}
+
+func TestReadPowerState(t *testing.T) {
+	// Initialise variables
+	mainsOnline := map[string]string{"AC/type": "Mains\n", "AC/online": "1\n"}
+	mainsOffline := map[string]string{"AC/type": "Mains\n", "AC/online": "0\n"}
+	mouse := BatterySupply("hid-mouse-battery", "5", "Discharging")
+	mouse["hid-mouse-battery/scope"] = "Device\n"
+	removed := BatterySupply("BAT1", "3", "Unknown")
+	removed["BAT1/present"] = "0\n"
+
+	tests := []struct {
+		name           string
+		files          map[string]string
+		wantState      PowerState
+		wantSufficient bool
+	}{
+		{"mains online, low battery", PowerSupplies(mainsOnline, BatterySupply("BAT0", "10", "Not charging")), PowerState{OnAC: true, Batteries: 1, Capacity: 10}, true},
+		{"battery below threshold", PowerSupplies(mainsOffline, BatterySupply("BAT0", "12", "Discharging")), PowerState{Batteries: 1, Capacity: 12}, false},
+		{"battery at threshold", PowerSupplies(mainsOffline, BatterySupply("BAT0", "30", "Discharging")), PowerState{Batteries: 1, Capacity: 30}, true},
+		{"battery above threshold", PowerSupplies(BatterySupply("BAT0", "85", "Discharging")), PowerState{Batteries: 1, Capacity: 85}, true},
+		{"charging battery", PowerSupplies(mainsOffline, BatterySupply("BAT0", "8", "Charging")), PowerState{OnAC: true, Batteries: 1, Capacity: 8}, true},
+		{"two batteries averaged", PowerSupplies(BatterySupply("BAT0", "10", "Discharging"), BatterySupply("BAT1", "40", "Discharging")), PowerState{Batteries: 2, Capacity: 25}, false},
+		{"device battery ignored", PowerSupplies(mainsOffline, mouse), PowerState{}, true},
+		{"battery not present", PowerSupplies(removed, BatterySupply("BAT0", "50", "Discharging")), PowerState{Batteries: 1, Capacity: 50}, true},
+		{"USB-C online", PowerSupplies(map[string]string{"ucsi/type": "USB\n", "ucsi/online": "1\n"}, BatterySupply("BAT0", "5", "Discharging")), PowerState{OnAC: true, Batteries: 1, Capacity: 5}, true},
+		{"no power supplies", nil, PowerState{}, true},
+	}
+	minBattery = 30
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Initialise variables
+			root := WriteTestFiles(t, test.files)
+			if err := os.MkdirAll(filepath.Join(root, POWER_SUPPLY_DIR), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			state, err := ReadPowerState(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if state != test.wantState {
+				t.Errorf("state = %+v, want %+v", state, test.wantState)
+			}
+			if sufficient := PowerSufficient(state); sufficient != test.wantSufficient {
+				t.Errorf("PowerSufficient(%s) = %v, want %v", state, sufficient, test.wantSufficient)
+			}
+		})
+	}
+
+	// Systems without power supplies in sysfs report an error, and are assumed to be on mains
+	if _, err := ReadPowerState(t.TempDir()); err == nil {
+		t.Error("missing power supply directory read without error")
+	}
+}
@@ -0,0 +1,163 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Privileges needed by each package manager
+
+package main
+
+// Import packages
+import (
+	"os"
+	"os/user"
+)
+
+// // Privilege models of package managers
+const PRIV_ELEVATED int = 0 // Runs with ROOT privileges
+const PRIV_USER int = 1     // Runs as the invoking user (refuses or misbehaves as root)
+const PRIV_BOTH int = 2     // Has separate system and user installations
+
+// // User-only setting, set by flags (or automatically, without ROOT privileges)
+var userOnlyFlag bool
+
+// A single way of running a package manager: elevated, or as a specific user
+type PrivilegeScope struct {
+	Label    string
+	Elevated bool
+	Account  *user.User
+	Args     []string
+}
+
+// Method to find the privilege model of a package manager
+func ManagerPrivilege(pkgNum int, official bool) int {
+	switch official {
+	// Alternative package managers
+	case false:
+		switch pkgNum {
+		// Brew package manager
+		case 0:
+			return PRIV_USER
+		// Flatpak package manager
+		case 3:
+			return PRIV_BOTH
+		}
+	}
+	return PRIV_ELEVATED
+}
+
+// Method to check if a package manager can update without ROOT privileges
+func IsUserLevelManager(pkgNum int, official bool) bool {
+	return ManagerPrivilege(pkgNum, official) != PRIV_ELEVATED
+}
+
+// Method to find the user who started update_full through sudo/doas, if running as root
+func InvokingUser() *user.User {
+	if os.Geteuid() != 0 {
+		return nil
+	}
+	for _, name := range []string{"SUDO_USER", "DOAS_USER"} {
+		username := os.Getenv(name)
+		if username == "" || username == "root" {
+			continue
+		}
+		if account, err := user.Lookup(username); err == nil {
+			return account
+		}
+	}
+	return nil
+}
+
+// Method to find the scopes a package manager runs in
+//
+// User-level managers run as the invoking user: directly when not root, or dropping
+// to SUDO_USER/DOAS_USER when update_full itself was started through sudo/doas.
+func PrivilegeScopes(pkgNum int, official bool) []PrivilegeScope {
+	// Initialise variables
+	var scopes []PrivilegeScope
+	var userScope *PrivilegeScope
+	privilege := ManagerPrivilege(pkgNum, official)
+
+	// Find the user-level scope, if there is a user to run as
+	switch {
+	case os.Geteuid() != 0 || OS_TYPE == "windows":
+		userScope = &PrivilegeScope{Label: "user"}
+	case InvokingUser() != nil:
+		account := InvokingUser()
+		userScope = &PrivilegeScope{Label: "user " + account.Username, Account: account}
+	}
+
+	switch privilege {
+	case PRIV_ELEVATED:
+		scopes = append(scopes, PrivilegeScope{Elevated: true})
+	case PRIV_USER:
+		if userScope != nil {
+			scopes = append(scopes, *userScope)
+		}
+	case PRIV_BOTH:
+		if !userOnlyFlag {
+			scopes = append(scopes, PrivilegeScope{Label: "system", Elevated: true, Args: []string{"--system"}})
+		}
+		if userScope != nil {
+			userScope.Args = []string{"--user"}
+			scopes = append(scopes, *userScope)
+		}
+	}
+
+	// Let the USER know about user-level managers without a user to run as
+	if len(scopes) == 0 || (privilege == PRIV_BOTH && userScope == nil) {
+		PrintWarning("\t* No invoking user to run user-level updates as (start update_full through sudo/doas, or as the user)")
+		runReport.AddNote("User-level updates skipped: running as root without SUDO_USER/DOAS_USER")
+	}
+	return scopes
+}
+
+// Method to build the environment of a command run as another user
+func AccountEnv(account *user.User) []string {
+	return []string{
+		"HOME=" + account.HomeDir,
+		"USER=" + account.Username,
+		"LOGNAME=" + account.Username,
+		"XDG_RUNTIME_DIR=/run/user/" + account.Uid,
+	}
+}
+
+// A package manager resolved for one scope, with what is needed to escalate it
+type ScopedManager struct {
+	Scope          PrivilegeScope
+	Path           string
+	Escalation     string
+	EscalationPath string
+	EnvPath        string
+	ShellPath      string
+	Home           string
+}
+
+// Method to build the command running the package manager with the given arguments
+//
+// Returns the command name, its arguments, and the options to run it with.
+func (manager ScopedManager) Command(args []string, env []string, interactive bool) (string, []string, CommandOptions) {
+	switch {
+	// sudo/doas are given a minimal, explicit environment through env -i
+	case manager.Escalation != "":
+		// Variables holding passwords are read by sh from a private file, as command lines can be read by every user
+		public, secret := SplitSecretEnv(env)
+		commandArgs := append([]string{manager.EnvPath, "-i"}, MinimalEnv(manager.Home, public)...)
+		if len(secret) > 0 {
+			path, err := WriteProxyEnvFile(secret)
+			switch {
+			case err != nil || manager.ShellPath == "":
+				PrintWarning("!!Could NOT pass the proxy password to [" + manager.Path + "] safely, running without the proxy")
+				logger.Warn("proxy not passed", "manager", manager.Path, "err", err)
+			default:
+				commandArgs = append(commandArgs, manager.ShellPath, "-c", SOURCE_ENV_SCRIPT, path)
+			}
+		}
+		commandArgs = append(commandArgs, manager.Path)
+		return manager.EscalationPath, append(commandArgs, args...), CommandOptions{Interactive: interactive}
+	// Already elevated, so only clean the environment
+	case manager.Scope.Elevated && OS_TYPE != "windows":
+		return manager.Path, args, CommandOptions{Env: MinimalEnv(manager.Home, env), CleanEnv: true, Interactive: interactive}
+	// User-level, possibly as another user
+	default:
+		return manager.Path, args, CommandOptions{Env: env, Account: manager.Scope.Account, Interactive: interactive}
+	}
+}
@@ -0,0 +1,107 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Named profiles bundling options, e.g. [profile.server], chosen with --profile
+
+package main
+
+// Import packages
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// // Tables holding profiles, and the key naming the profile they inherit from
+const PROFILE_TABLE_PREFIX string = "profile."
+const PROFILE_INHERITS_KEY string = "inherits"
+
+// // Profile in use, set from configuration
+var activeProfile string
+
+// Method to add a key of a [profile.<name>] table to its profile
+func (config *Config) AddProfileEntry(path string, entry ConfigEntry) error {
+	// Initialise variables
+	name := strings.TrimPrefix(entry.Table, PROFILE_TABLE_PREFIX)
+	source := path + " [" + entry.Table + "]"
+
+	switch {
+	case name == "" || strings.Contains(name, "."):
+		return fmt.Errorf("%s: line %d: invalid profile name [%s]", path, entry.Line, name)
+	case entry.Key == PROFILE_INHERITS_KEY:
+		if entry.IsList || len(entry.Values) != 1 {
+			return fmt.Errorf("%s: line %d: %s must name a single profile", path, entry.Line, PROFILE_INHERITS_KEY)
+		}
+	case entry.Key == "profile":
+		return fmt.Errorf("%s: line %d: profiles can not choose a profile, use %s", path, entry.Line, PROFILE_INHERITS_KEY)
+	default:
+		if err := CheckConfigKey(entry.Key); err != nil {
+			return fmt.Errorf("%s: line %d: %w", path, entry.Line, err)
+		}
+	}
+
+	// Later files add to (and override keys of) profiles of the same name
+	if config.Profiles[name] == nil {
+		config.Profiles[name] = map[string]ConfigSetting{}
+	}
+	config.Profiles[name][entry.Key] = ConfigSetting{Values: entry.Values, IsList: entry.IsList, Source: source}
+	return nil
+}
+
+// Method to list the names of every profile
+func (config *Config) ProfileNames() []string {
+	// Initialise variables
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Method to list the configured profiles, for shell completion
+func ProfileChoices(config *Config) []string {
+	return config.ProfileNames()
+}
+
+// Method to resolve a profile and those it inherits from into its settings
+//
+// Returns the settings, and the chain of profiles from the chosen one to its base.
+func (config *Config) ResolveProfile(name string) (map[string]ConfigSetting, []string, error) {
+	// Initialise variables
+	var chain []string
+	visited := map[string]bool{}
+	settings := map[string]ConfigSetting{}
+
+	// Follow inheritance up to the base profile, refusing cycles
+	for current := name; current != ""; {
+		if visited[current] {
+			return nil, nil, errors.New("profile [" + name + "] inherits from itself (" + strings.Join(append(chain, current), " < ") + ")")
+		}
+		profile, found := config.Profiles[current]
+		if !found {
+			available := strings.Join(config.ProfileNames(), ", ")
+			if available == "" {
+				available = "none configured"
+			}
+			return nil, nil, errors.New("unknown profile [" + current + "] (available: " + available + ")")
+		}
+		visited[current] = true
+		chain = append(chain, current)
+		current = ""
+		if inherits, found := profile[PROFILE_INHERITS_KEY]; found {
+			current = inherits.Values[0]
+		}
+	}
+
+	// Apply the base profile first, so each profile overrides those it inherits from
+	for i := len(chain) - 1; i >= 0; i-- {
+		for key, setting := range config.Profiles[chain[i]] {
+			if key != PROFILE_INHERITS_KEY {
+				settings[key] = setting
+			}
+		}
+	}
+	return settings, chain, nil
+}
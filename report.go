@@ -0,0 +1,132 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Run report, recording every package manager step taken
+
+package main
+
+// Import packages
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// // Possible statuses of a package manager step
+const STEP_OK string = "OK"
+const STEP_FAILED string = "FAILED"
+const STEP_SKIPPED string = "SKIPPED"
+const STEP_RETRIED string = "RETRIED"
+
+// Record of a single package manager step
+type StepRecord struct {
+	Manager  string
+	Command  []string
+	Status   string
+	Attempt  int
+	Err      error
+	Duration time.Duration
+}
+
+// Report of the whole run, printed at the end (or when cancelled)
+type RunReport struct {
+	mutex     sync.Mutex
+	Begin     time.Time
+	Steps     []StepRecord
+	Notes     []string
+	Cancelled bool
+}
+
+// // Report of the current run
+var runReport RunReport = RunReport{Begin: time.Now()}
+
+// Method to add a package manager step to the report
+func (report *RunReport) AddStep(manager string, command []string, status string, err error, duration time.Duration) {
+	report.AddAttempt(manager, command, status, 1, err, duration)
+}
+
+// Method to add a single attempt of a (possibly retried) package manager step to the report
+func (report *RunReport) AddAttempt(manager string, command []string, status string, attempt int, err error, duration time.Duration) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.Steps = append(report.Steps, StepRecord{
+		Manager:  manager,
+		Command:  command,
+		Status:   status,
+		Attempt:  attempt,
+		Err:      err,
+		Duration: duration,
+	})
+}
+
+// Method to add a free-form note to the report
+func (report *RunReport) AddNote(note string) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.Notes = append(report.Notes, note)
+}
+
+// Method to mark the report as cancelled by USER
+func (report *RunReport) MarkCancelled() {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.Cancelled = true
+}
+
+// Method to print the report
+func (report *RunReport) Print() {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+
+	fmt.Println(" = = =")
+	switch report.Cancelled {
+	case true:
+		fmt.Println(Colorize(COLOR_YELLOW, "Run report (PARTIAL, cancelled by USER):"))
+	case false:
+		fmt.Println("Run report:")
+	}
+	// Print notes first
+	for _, note := range report.Notes {
+		fmt.Println("\t* " + note)
+	}
+	// Print every step taken
+	for _, step := range report.Steps {
+		fmt.Printf("\t%s %s: %s (%s)", Colorize(StatusColor(step.Status), "["+step.Status+"]"), step.Manager, RedactSecrets(strings.Join(step.Command, " ")), step.Duration.Round(time.Millisecond))
+		if step.Attempt > 1 {
+			fmt.Printf(" [attempt %d]", step.Attempt)
+		}
+		fmt.Println()
+		switch step.Err {
+		case nil:
+		default:
+			fmt.Println("\t\t" + RedactSecrets(step.Err.Error()))
+		}
+	}
+	fmt.Println("Total time:", time.Since(report.Begin))
+}
+
+// Method to find the color of a step status
+func StatusColor(status string) string {
+	switch status {
+	case STEP_OK:
+		return COLOR_GREEN
+	case STEP_FAILED:
+		return COLOR_RED
+	default:
+		return COLOR_YELLOW
+	}
+}
+
+// Method to check if any step failed
+func (report *RunReport) Failed() bool {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+
+	for _, step := range report.Steps {
+		if step.Status == STEP_FAILED {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,400 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Extraction and probing of the repositories configured for each package manager
+
+package main
+
+// Import packages
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// // Maximum number of mirrors probed from a mirrorlist
+const MAX_PROBED_MIRRORS int = 3
+
+// // Repository settings, set by flags
+var skipRepoCheck bool
+var repoConfigRoot string = "/"
+
+// A repository configured for a package manager
+//
+// Repositories sharing a Group are mirrors of each other, so one reachable mirror is enough.
+type Repository struct {
+	Manager string
+	Name    string
+	URL     string
+	Group   string
+}
+
+// Method to read the lines of a file as they are, ignoring missing files
+func ReadRawConfigLines(path string) []string {
+	// Initialise variables
+	var lines []string
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), " \t\r"))
+	}
+	return lines
+}
+
+// Method to read the lines of a file, trimmed, ignoring missing files
+func ReadConfigLines(path string) []string {
+	// Initialise variables
+	lines := ReadRawConfigLines(path)
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	return lines
+}
+
+// Method to list files in a directory matching a pattern, sorted by name
+func ConfigFiles(root string, pattern string) []string {
+	matches, _ := filepath.Glob(filepath.Join(root, pattern))
+	return matches
+}
+
+// Method to extract repositories from Apt's one-line format (sources.list, *.list)
+func ParseAptList(path string) []Repository {
+	// Initialise variables
+	var repos []Repository
+	for _, line := range ReadConfigLines(path) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || (fields[0] != "deb" && fields[0] != "deb-src") {
+			continue
+		}
+		// Skip options, such as [arch=amd64 signed-by=...]
+		fields = fields[1:]
+		if strings.HasPrefix(fields[0], "[") {
+			for len(fields) > 0 && !strings.HasSuffix(fields[0], "]") {
+				fields = fields[1:]
+			}
+			if len(fields) > 0 {
+				fields = fields[1:]
+			}
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		name := fields[0]
+		if len(fields) > 1 {
+			name += " " + fields[1]
+		}
+		repos = append(repos, Repository{Manager: "apt", Name: name, URL: fields[0]})
+	}
+	return repos
+}
+
+// Method to extract repositories from Apt's deb822 format (*.sources)
+func ParseAptSources(path string) []Repository {
+	// Initialise variables
+	var repos []Repository
+	var uris []string
+	var suites []string
+	var enabled bool = true
+	var lastKey string
+
+	// Stanzas are separated by blank lines, so finish each one at a blank line (or the end)
+	finishStanza := func() {
+		for _, uri := range uris {
+			name := strings.TrimSpace(uri + " " + strings.Join(suites, " "))
+			if enabled {
+				repos = append(repos, Repository{Manager: "apt", Name: name, URL: uri})
+			}
+		}
+		uris, suites, enabled, lastKey = nil, nil, true, ""
+	}
+	for _, line := range append(ReadRawConfigLines(path), "") {
+		switch {
+		case strings.HasPrefix(line, "#"):
+		case strings.TrimSpace(line) == "":
+			finishStanza()
+		case strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t"):
+			// Continuation of the previous field, where "." stands for an empty line
+			value := strings.TrimSpace(line)
+			if value == "." {
+				continue
+			}
+			switch lastKey {
+			case "uris":
+				uris = append(uris, strings.Fields(value)...)
+			case "suites":
+				suites = append(suites, strings.Fields(value)...)
+			}
+		default:
+			key, value, found := strings.Cut(line, ":")
+			if !found {
+				lastKey = ""
+				continue
+			}
+			lastKey = strings.ToLower(strings.TrimSpace(key))
+			switch lastKey {
+			case "uris":
+				uris = append(uris, strings.Fields(value)...)
+			case "suites":
+				suites = append(suites, strings.Fields(value)...)
+			case "enabled":
+				enabled = strings.ToLower(strings.TrimSpace(value)) != "no"
+			}
+		}
+	}
+	return repos
+}
+
+// Method to extract repositories from INI-style *.repo files (Dnf, Yum and Zypper)
+func ParseRepoFile(path string, manager string) []Repository {
+	// Initialise variables
+	var repos []Repository
+	var section string
+	var name string
+	var urls []string
+	var enabled bool = true
+	var lastKey string
+
+	// Finish each section when the next one begins (or at the end)
+	finishSection := func() {
+		if section != "" && enabled {
+			if name == "" {
+				name = section
+			}
+			for _, rawURL := range urls {
+				repos = append(repos, Repository{Manager: manager, Name: name, URL: rawURL, Group: manager + ":" + section})
+			}
+		}
+		section, name, urls, enabled, lastKey = "", "", nil, true, ""
+	}
+	for _, line := range ReadConfigLines(path) {
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			finishSection()
+			section = strings.Trim(line, "[]")
+		case !strings.Contains(line, "=") && lastKey == "baseurl":
+			// Continuation of a multi-line baseurl
+			urls = append(urls, strings.Fields(line)...)
+		default:
+			key, value, _ := strings.Cut(line, "=")
+			lastKey = strings.ToLower(strings.TrimSpace(key))
+			value = strings.TrimSpace(value)
+			switch lastKey {
+			case "name":
+				name = value
+			case "baseurl":
+				urls = append(urls, strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })...)
+			case "mirrorlist", "metalink":
+				urls = append(urls, value)
+			case "enabled":
+				enabled = value != "0" && strings.ToLower(value) != "false"
+			}
+		}
+	}
+	finishSection()
+	return repos
+}
+
+// Method to extract repositories from /etc/apk/repositories
+func ParseApkRepositories(path string) []Repository {
+	// Initialise variables
+	var repos []Repository
+	for _, line := range ReadConfigLines(path) {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Tagged repositories look like "@testing https://..."
+		fields := strings.Fields(line)
+		repos = append(repos, Repository{Manager: "apk", Name: line, URL: fields[len(fields)-1]})
+	}
+	return repos
+}
+
+// Method to extract the first mirrors from Pacman's mirrorlist
+func ParsePacmanMirrorlist(path string) []Repository {
+	// Initialise variables
+	var repos []Repository
+	for _, line := range ReadConfigLines(path) {
+		key, value, found := strings.Cut(line, "=")
+		if !found || strings.TrimSpace(key) != "Server" {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		repos = append(repos, Repository{Manager: "pacman", Name: "mirrorlist", URL: value, Group: "pacman:mirrorlist"})
+		if len(repos) == MAX_PROBED_MIRRORS {
+			break
+		}
+	}
+	return repos
+}
+
+// Method to list Flatpak remotes, from both system and user installations
+func FlatpakRemotes() []Repository {
+	// Initialise variables
+	var repos []Repository
+	path, options, err := ResolveHelper("flatpak", nil)
+	if err != nil {
+		return nil
+	}
+	stdout, _, err := RunCommand(options, path, "remotes", "--columns=name,url")
+	if err != nil {
+		return nil
+	}
+	for _, line := range strings.Split(string(stdout), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		repos = append(repos, Repository{Manager: "flatpak", Name: fields[0], URL: fields[1]})
+	}
+	return repos
+}
+
+// Method to find the repositories of the detected package managers, from their configuration under root
+func FindRepositories(root string, managers []DetectedManager) []Repository {
+	// Initialise variables
+	var repos []Repository
+
+	for _, manager := range managers {
+		name := PkgManagerName(manager.PkgNum, manager.Official)
+		switch manager.Official {
+		// Official package managers
+		case true:
+			switch manager.PkgNum {
+			// Apt package manager
+			case 0:
+				for _, path := range append(ConfigFiles(root, "etc/apt/sources.list"), ConfigFiles(root, "etc/apt/sources.list.d/*.list")...) {
+					repos = append(repos, ParseAptList(path)...)
+				}
+				for _, path := range ConfigFiles(root, "etc/apt/sources.list.d/*.sources") {
+					repos = append(repos, ParseAptSources(path)...)
+				}
+			// Dnf, Yum & Rpm-ostree package managers
+			case 1, 4, 5:
+				for _, path := range ConfigFiles(root, "etc/yum.repos.d/*.repo") {
+					repos = append(repos, ParseRepoFile(path, name)...)
+				}
+			// Transactional-update & Zypper package managers
+			case 2, 3:
+				for _, path := range ConfigFiles(root, "etc/zypp/repos.d/*.repo") {
+					repos = append(repos, ParseRepoFile(path, name)...)
+				}
+			// Apk package manager
+			case 6:
+				repos = append(repos, ParseApkRepositories(filepath.Join(root, "etc/apk/repositories"))...)
+			// Pacman package manager
+			case 8:
+				repos = append(repos, ParsePacmanMirrorlist(filepath.Join(root, "etc/pacman.d/mirrorlist"))...)
+			}
+		// Alternative package managers
+		case false:
+			switch manager.PkgNum {
+			// Flatpak package manager
+			case 3:
+				repos = append(repos, FlatpakRemotes()...)
+			}
+		}
+	}
+
+	return repos
+}
+
+// Method to turn a repository URL into a probe target, or "" if it cannot be probed
+func RepositoryTarget(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		return ""
+	}
+	// Variables such as $releasever cannot be resolved here, so only the server is probed
+	if strings.Contains(parsedURL.Host, "$") {
+		return ""
+	}
+	return parsedURL.Scheme + "://" + parsedURL.Host + "/"
+}
+
+// Method to probe all repositories concurrently, returning the names of unreachable ones
+func ProbeRepositories(repos []Repository) []string {
+	// Initialise variables
+	var targets []string
+	var unreachable []string
+	targetIndex := map[string]int{}
+	var groups []string
+	groupReachable := map[string]bool{}
+	groupRepos := map[string]Repository{}
+
+	// Probe each server only once
+	for _, repo := range repos {
+		target := RepositoryTarget(repo.URL)
+		if _, found := targetIndex[target]; target != "" && !found {
+			targetIndex[target] = len(targets)
+			targets = append(targets, target)
+		}
+	}
+	checker := NewConnectivityChecker()
+	checker.CaptivePortalURL = ""
+	results := checker.CheckTargets(context.Background(), targets)
+
+	// Match results back to repositories, reporting each one once
+	seen := map[Repository]bool{}
+	for _, repo := range repos {
+		if seen[repo] {
+			continue
+		}
+		seen[repo] = true
+		target := RepositoryTarget(repo.URL)
+		if target == "" {
+			logger.Debug("repository not probed", "manager", repo.Manager, "repository", repo.Name, "url", repo.URL)
+			continue
+		}
+		result := results[targetIndex[target]]
+		switch repo.Group {
+		case "":
+			if result.Verdict() != VERDICT_OK {
+				PrintFailure("!!Repository [" + repo.Name + "] (" + repo.Manager + ") unreachable: " + result.Verdict())
+				unreachable = append(unreachable, repo.Name)
+			}
+		default:
+			if _, found := groupRepos[repo.Group]; !found {
+				groups = append(groups, repo.Group)
+				groupRepos[repo.Group] = repo
+			}
+			groupReachable[repo.Group] = groupReachable[repo.Group] || result.Verdict() == VERDICT_OK
+		}
+	}
+	for _, group := range groups {
+		if !groupReachable[group] {
+			repo := groupRepos[group]
+			PrintFailure("!!Repository [" + repo.Name + "] (" + repo.Manager + ") unreachable: no mirror reachable")
+			unreachable = append(unreachable, repo.Name)
+		}
+	}
+
+	return unreachable
+}
+
+// Method to check that the repositories of detected package managers are reachable
+func RepositoryCheck(aoFlag bool, ooFlag bool) error {
+	repos := FindRepositories(repoConfigRoot, DetectedManagers(aoFlag, ooFlag, yumUpdateFlag))
+	switch len(repos) {
+	case 0:
+		PrintStatus("* No repositories found to test")
+		return nil
+	}
+	PrintStatus("* Testing", len(repos), "configured repositories...")
+	unreachable := ProbeRepositories(repos)
+	switch len(unreachable) {
+	case 0:
+		PrintSuccess("* All configured repositories reachable!")
+		return nil
+	default:
+		return errors.New("unreachable repositories [" + strings.Join(unreachable, "], [") + "]")
+	}
+}
@@ -0,0 +1,196 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Tests of the repository parsers, against configuration files in a temporary root
+
+package main
+
+// Import packages
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Method to write files under a temporary root, returning the root
+func WriteTestFiles(t *testing.T, files map[string]string) string {
+	// Initialise variables
+	root := t.TempDir()
+
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// Method to list the URLs of repositories, for comparison
+func RepositoryURLs(repos []Repository) []string {
+	// Initialise variables
+	var urls []string
+	for _, repo := range repos {
+		urls = append(urls, repo.URL)
+	}
+	return urls
+}
+
+func TestRepositoryParsers(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		parse    func(path string) []Repository
+		wantURLs []string
+	}{
+		{
+			name: "apt list",
+			content: "# comment\n" +
+				"deb http://deb.debian.org/debian bookworm main\n" +
+				"deb-src http://deb.debian.org/debian bookworm main\n" +
+				"deb [arch=amd64 signed-by=/usr/share/keyrings/key.gpg] https://repo.example.com/apt stable main\n" +
+				"deb [ trusted=yes ] file:/srv/local ./\n" +
+				"#deb http://disabled.example.com/ stable main\n",
+			parse:    ParseAptList,
+			wantURLs: []string{"http://deb.debian.org/debian", "http://deb.debian.org/debian", "https://repo.example.com/apt", "file:/srv/local"},
+		},
+		{
+			name: "apt deb822",
+			content: "Types: deb\n" +
+				"URIs: http://deb.debian.org/debian\n" +
+				"Suites: bookworm bookworm-updates\n" +
+				"Components: main\n" +
+				"\n" +
+				"# Disabled stanza\n" +
+				"Types: deb\n" +
+				"URIs: https://disabled.example.com/\n" +
+				"Suites: stable\n" +
+				"Enabled: no\n" +
+				"\n" +
+				"Types: deb\n" +
+				"URIs:\n" +
+				" https://mirror1.example.com/debian\n" +
+				"\thttps://mirror2.example.com/debian\n" +
+				"Suites: stable\n" +
+				"Signed-By:\n" +
+				" -----BEGIN PGP PUBLIC KEY BLOCK-----\n" +
+				" .\n" +
+				" mQINBGPL0BUBEADmW5NTOj8Bl0Vf: not a key\n" +
+				" -----END PGP PUBLIC KEY BLOCK-----\n",
+			parse:    ParseAptSources,
+			wantURLs: []string{"http://deb.debian.org/debian", "https://mirror1.example.com/debian", "https://mirror2.example.com/debian"},
+		},
+		{
+			name: "yum repo",
+			content: "[fedora]\n" +
+				"name=Fedora $releasever\n" +
+				"metalink=https://mirrors.fedoraproject.org/metalink?repo=fedora-$releasever\n" +
+				"enabled=1\n" +
+				"\n" +
+				"[internal]\n" +
+				"baseurl=https://mirror1.example.com/el9,\n" +
+				"        https://mirror2.example.com/el9\n" +
+				"\n" +
+				"[disabled]\n" +
+				"baseurl=https://disabled.example.com/\n" +
+				"enabled=0\n",
+			parse:    func(path string) []Repository { return ParseRepoFile(path, "dnf") },
+			wantURLs: []string{"https://mirrors.fedoraproject.org/metalink?repo=fedora-$releasever", "https://mirror1.example.com/el9", "https://mirror2.example.com/el9"},
+		},
+		{
+			name: "apk repositories",
+			content: "https://dl-cdn.alpinelinux.org/alpine/v3.19/main\n" +
+				"# https://dl-cdn.alpinelinux.org/alpine/v3.19/community\n" +
+				"@testing https://dl-cdn.alpinelinux.org/alpine/edge/testing\n",
+			parse:    ParseApkRepositories,
+			wantURLs: []string{"https://dl-cdn.alpinelinux.org/alpine/v3.19/main", "https://dl-cdn.alpinelinux.org/alpine/edge/testing"},
+		},
+		{
+			name: "pacman mirrorlist",
+			content: "## Worldwide\n" +
+				"#Server = https://disabled.example.com/$repo/os/$arch\n" +
+				"Server = https://geo.mirror.pkgbuild.com/$repo/os/$arch\n" +
+				"Server=https://mirror1.example.com/$repo/os/$arch\n" +
+				"Server = https://mirror2.example.com/$repo/os/$arch\n" +
+				"Server = https://mirror3.example.com/$repo/os/$arch\n",
+			parse:    ParsePacmanMirrorlist,
+			wantURLs: []string{"https://geo.mirror.pkgbuild.com/$repo/os/$arch", "https://mirror1.example.com/$repo/os/$arch", "https://mirror2.example.com/$repo/os/$arch"},
+		},
+		{
+			name:     "missing file",
+			parse:    ParseAptList,
+			wantURLs: nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Initialise variables
+			path := filepath.Join(t.TempDir(), "config")
+			if test.content != "" {
+				if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if urls := RepositoryURLs(test.parse(path)); !reflect.DeepEqual(urls, test.wantURLs) {
+				t.Errorf("URLs = %q, want %q", urls, test.wantURLs)
+			}
+		})
+	}
+}
+
+func TestFindRepositories(t *testing.T) {
+	root := WriteTestFiles(t, map[string]string{
+		"etc/apt/sources.list":               "deb http://deb.debian.org/debian bookworm main\n",
+		"etc/apt/sources.list.d/extra.list":  "deb https://extra.example.com/apt stable main\n",
+		"etc/apt/sources.list.d/deb.sources": "Types: deb\nURIs: https://deb822.example.com/\nSuites: stable\n",
+		"etc/yum.repos.d/stray.repo":         "[stray]\nbaseurl=https://stray.example.com/\n",
+		"etc/zypp/repos.d/oss.repo":          "[oss]\nbaseurl=https://download.opensuse.org/tumbleweed/repo/oss/\n",
+		"etc/apk/repositories":               "https://dl-cdn.alpinelinux.org/alpine/v3.19/main\n",
+		"etc/pacman.d/mirrorlist":            "Server = https://geo.mirror.pkgbuild.com/$repo/os/$arch\n",
+	})
+
+	tests := []struct {
+		name     string
+		managers []DetectedManager
+		wantURLs []string
+	}{
+		{"nothing detected", nil, nil},
+		{"apt only ignores stray yum repos", []DetectedManager{{PkgNum: 0, Official: true}}, []string{"http://deb.debian.org/debian", "https://extra.example.com/apt", "https://deb822.example.com/"}},
+		{"dnf", []DetectedManager{{PkgNum: 1, Official: true}}, []string{"https://stray.example.com/"}},
+		{"zypper", []DetectedManager{{PkgNum: 3, Official: true}}, []string{"https://download.opensuse.org/tumbleweed/repo/oss/"}},
+		{"apk", []DetectedManager{{PkgNum: 6, Official: true}}, []string{"https://dl-cdn.alpinelinux.org/alpine/v3.19/main"}},
+		{"pacman", []DetectedManager{{PkgNum: 8, Official: true}}, []string{"https://geo.mirror.pkgbuild.com/$repo/os/$arch"}},
+		{"alternative without repositories", []DetectedManager{{PkgNum: 0, Official: false}}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if urls := RepositoryURLs(FindRepositories(root, test.managers)); !reflect.DeepEqual(urls, test.wantURLs) {
+				t.Errorf("URLs = %q, want %q", urls, test.wantURLs)
+			}
+		})
+	}
+}
+
+func TestRepositoryTarget(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"http://deb.debian.org/debian", "http://deb.debian.org/"},
+		{"https://mirrors.fedoraproject.org/metalink?repo=fedora-$releasever", "https://mirrors.fedoraproject.org/"},
+		{"https://mirror.example.com:8443/el9", "https://mirror.example.com:8443/"},
+		{"https://$mirror/el9", ""},
+		{"file:/srv/local", ""},
+		{"cdrom:[Debian]/", ""},
+	}
+	for _, test := range tests {
+		if got := RepositoryTarget(test.url); got != test.want {
+			t.Errorf("RepositoryTarget(%q) = %q, want %q", test.url, got, test.want)
+		}
+	}
+}
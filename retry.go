@@ -0,0 +1,162 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Retrying of network-bound package manager steps
+
+package main
+
+// Import packages
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// // Retry settings, set by flags
+var retryAttempts int = 3
+var retryDelay time.Duration = 2 * time.Second
+var retryMaxDelay time.Duration = 30 * time.Second
+
+// // Error output shared by most package managers when the network is at fault
+var NETWORK_ERROR_PATTERNS []string = []string{
+	"could not resolve",
+	"temporary failure in name resolution",
+	"temporary failure resolving",
+	"name or service not known",
+	"no address associated with hostname",
+	"network is unreachable",
+	"connection timed out",
+	"connection refused",
+	"connection reset",
+	"operation timed out",
+	"tls handshake timeout",
+}
+
+// Method to check if a step of a package manager needs the network (refreshes and downloads)
+func IsNetworkStep(pkgNum int, official bool, step int) bool {
+	switch official {
+	// Official package managers
+	case true:
+		switch pkgNum {
+		// Apt: update, dist-upgrade, -f install
+		case 0:
+			return step <= 2
+		// Dnf & Yum: check-update, update
+		case 1, 4:
+			return step <= 1
+		// OpenSUSE immutable: all
+		case 2:
+			return true
+		// Zypper: list-updates, patch-check, update, patch
+		case 3:
+			return step <= 3
+		// Rpm-Ostree: upgrade --check, upgrade
+		case 5:
+			return step >= 1
+		// Apk: update, upgrade
+		case 6:
+			return step <= 1
+		// Clear Linux, Arch Linux, OpenBSD, Solus Linux, Winget: all
+		case 7, 8, 9, 11, 14:
+			return true
+		// FreeBSD: update, upgrade, audit -F
+		case 10:
+			return step <= 1 || step == 4
+		// Slackware Linux: update, install-new, upgrade-all
+		case 12:
+			return step <= 2
+		}
+	// Alternative package managers
+	case false:
+		switch pkgNum {
+		// Brew: update, upgrade
+		case 0:
+			return step <= 1
+		// Snap, Chocolatey: all
+		case 1, 2:
+			return true
+		// Flatpak: update
+		case 3:
+			return step == 0
+		}
+	}
+	return false
+}
+
+// Method to check if a failed step was caused by the network, rather than a real failure
+func IsNetworkFailure(pkgNum int, official bool, err error, stderr string) bool {
+	// Initialise variables
+	var patterns []string = NETWORK_ERROR_PATTERNS
+	var exitErr *exec.ExitError
+	stderr = strings.ToLower(stderr)
+
+	// Only Apt reports failed refreshes with a successful exit code
+	if err == nil {
+		return official && pkgNum == 0 && strings.Contains(stderr, "some index files failed to download")
+	}
+
+	// Add patterns and exit codes specific to package managers
+	switch official {
+	case true:
+		switch pkgNum {
+		// Apt package manager
+		case 0:
+			patterns = append(patterns, "failed to fetch", "some index files failed to download", "unable to connect to")
+		// Dnf & Yum package manager
+		case 1, 4:
+			patterns = append(patterns, "curl error", "cannot download repomd.xml", "failed to download metadata", "errors during downloading metadata")
+		// Zypper package manager
+		case 2, 3:
+			// ZYPPER_EXIT_INF_REPOS_SKIPPED: repositories skipped due to refresh errors
+			if errors.As(err, &exitErr) && exitErr.ExitCode() == 106 {
+				return true
+			}
+			patterns = append(patterns, "download (curl) error", "problem retrieving files from")
+		// Rpm-Ostree
+		case 5:
+			patterns = append(patterns, "while fetching", "curl: ")
+		// Apk
+		case 6:
+			patterns = append(patterns, "temporary error (try again later)", "network error", "dns lookup error")
+		// Clear Linux
+		case 7:
+			patterns = append(patterns, "failed to connect to update server", "curl error")
+		// Arch Linux
+		case 8:
+			patterns = append(patterns, "failed retrieving file", "failed to synchronize")
+		// FreeBSD
+		case 10:
+			patterns = append(patterns, "unable to update repository", "no address record")
+		}
+	case false:
+		switch pkgNum {
+		// Brew package manager
+		case 0:
+			patterns = append(patterns, "failed to download resource", "curl: (")
+		// Flatpak package manager
+		case 3:
+			patterns = append(patterns, "while fetching", "unable to connect")
+		}
+	}
+
+	// Compare stderr with all patterns
+	for _, pattern := range patterns {
+		if strings.Contains(stderr, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// Method to calculate the exponential backoff before a given attempt (starting from 1)
+func RetryBackoff(attempt int) time.Duration {
+	delay := retryDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= retryMaxDelay {
+			return retryMaxDelay
+		}
+	}
+	return delay
+}
@@ -0,0 +1,337 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Interactive selection of pending updates to upgrade
+
+package main
+
+// Import packages
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// // Selection setting, set by flags
+var selectFlag bool
+
+// Method to find the step upgrading packages, if the package manager can target single packages
+func SelectionStep(pkgNum int, official bool) (int, bool) {
+	switch official {
+	// Official package managers
+	case true:
+		switch pkgNum {
+		// Apt (dist-upgrade) & Dnf/Yum (update) package managers
+		case 0, 1, 4:
+			return 1, true
+		// Zypper package manager (update)
+		case 3:
+			return 2, true
+		}
+	// Alternative package managers
+	case false:
+		switch pkgNum {
+		// Brew package manager (upgrade)
+		case 0:
+			return 1, true
+		// Flatpak package manager (update)
+		case 3:
+			return 0, true
+		}
+	}
+	return -1, false
+}
+
+// Method to check if a step upgrades every package, regardless of the selection
+func IsBulkUpgradeStep(pkgNum int, official bool, step int) bool {
+	// Zypper patch installs every needed patch
+	return official && pkgNum == 3 && step == 3
+}
+
+// Method to find the arguments listing pending updates of a package manager
+func ListUpdatesArgs(pkgNum int, official bool) []string {
+	switch official {
+	// Official package managers
+	case true:
+		switch pkgNum {
+		// Apt package manager
+		case 0:
+			return []string{"list", "--upgradable"}
+		// Dnf & Yum package manager: only from the cache, if offline
+		case 1, 4:
+			switch offlineFlag {
+			case true:
+				return []string{"-C", "-q", "check-update"}
+			}
+			return []string{"-q", "check-update"}
+		// Zypper package manager
+		case 3:
+			return []string{"--non-interactive", "list-updates"}
+		}
+	// Alternative package managers
+	case false:
+		switch pkgNum {
+		// Brew package manager
+		case 0:
+			return []string{"outdated", "--quiet"}
+		// Flatpak package manager: only from the cache, if offline
+		case 3:
+			switch offlineFlag {
+			case true:
+				return []string{"remote-ls", "--updates", "--cached", "--columns=ref"}
+			}
+			return []string{"remote-ls", "--updates", "--columns=ref"}
+		}
+	}
+	return nil
+}
+
+// Method to find the actions upgrading only the given packages
+func TargetedUpgradeArgs(pkgNum int, official bool, packages []string) []string {
+	// Initialise variables
+	var actions []string
+	switch official {
+	// Official package managers
+	case true:
+		switch pkgNum {
+		// Apt package manager
+		case 0:
+			actions = []string{"install", "--only-upgrade"}
+		// Dnf & Yum package manager
+		case 1, 4:
+			actions = []string{"upgrade"}
+		// Zypper package manager
+		case 3:
+			actions = []string{"update"}
+		}
+	// Alternative package managers
+	case false:
+		switch pkgNum {
+		// Brew package manager
+		case 0:
+			actions = []string{"upgrade"}
+		// Flatpak package manager
+		case 3:
+			actions = []string{"update"}
+		}
+	}
+	return append(actions, packages...)
+}
+
+// Method to parse the pending updates listed by a package manager
+func ParsePendingUpdates(pkgNum int, official bool, output string) []string {
+	// Initialise variables
+	var packages []string
+	// Name too long for its column, with the rest of the entry on the next line (Dnf & Yum)
+	var wrapped string
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch official {
+		// Official package managers
+		case true:
+			switch pkgNum {
+			// Apt package manager: name/suite version arch [upgradable from: version]
+			case 0:
+				if strings.Contains(line, "[upgradable from:") {
+					packages = append(packages, strings.SplitN(fields[0], "/", 2)[0])
+				}
+			// Dnf & Yum package manager: name.arch version repository
+			case 1, 4:
+				// Obsoleted packages are listed last, and only upgrade along the rest
+				if strings.HasPrefix(line, "Obsoleting") {
+					return packages
+				}
+				if wrapped != "" && len(fields) == 2 {
+					fields = append([]string{wrapped}, fields...)
+				}
+				wrapped = ""
+				switch {
+				case len(fields) == 3 && strings.Contains(fields[0], "."):
+					packages = append(packages, fields[0])
+				case len(fields) == 1 && strings.Contains(fields[0], "."):
+					wrapped = fields[0]
+				}
+			// Zypper package manager: S | Repository | Name | Current Version | Available Version | Arch
+			case 3:
+				columns := strings.Split(line, "|")
+				if len(columns) >= 5 && strings.TrimSpace(columns[0]) == "v" {
+					packages = append(packages, strings.TrimSpace(columns[2]))
+				}
+			}
+		// Alternative package managers
+		case false:
+			switch pkgNum {
+			// Brew package manager: one formula per line
+			case 0:
+				packages = append(packages, fields[0])
+			// Flatpak package manager: one ref per line
+			case 3:
+				if strings.Count(fields[0], "/") == 3 {
+					packages = append(packages, fields[0])
+				}
+			}
+		}
+	}
+	return packages
+}
+
+// Method to list pending updates of a package manager, and let the USER pick which to upgrade
+//
+// Returns the selected packages, whether the upgrade should target them (or run as usual,
+// if they could not be listed), and the decision of the USER on the whole package manager.
+func SelectPendingUpdates(manager ScopedManager, pkgNum int, official bool, label string, extraArgs []string, env []string) ([]string, bool, string) {
+	// List pending updates
+	PrintStatus("* Listing pending updates for [" + label + "]...")
+	packages, stderr, err := ListPendingUpdates(manager, pkgNum, official, extraArgs, env)
+	if err != nil {
+		PrintWarning("!!Could NOT list pending updates for ["+label+"]:", err)
+		if len(stderr) > 0 {
+			PrintWarning(strings.TrimSpace(string(stderr)))
+		}
+		return nil, false, ConfirmManager(label)
+	}
+
+	if len(packages) == 0 {
+		PrintStatus("* No pending updates for [" + label + "]")
+		return nil, true, DECISION_RUN
+	}
+	selected, decision := SelectFromChecklist(label, packages)
+	return selected, true, decision
+}
+
+// Method to list the pending updates of a package manager, without changing anything
+//
+// Returns the packages, and the standard error of the listing command.
+func ListPendingUpdates(manager ScopedManager, pkgNum int, official bool, extraArgs []string, env []string) ([]string, []byte, error) {
+	name, args, options := manager.Command(append(ListUpdatesArgs(pkgNum, official), extraArgs...), env, false)
+	stdout, stderr, err := RunCommand(options, name, args...)
+
+	// Listing runs the first step's command for Dnf & Yum (check-update), exiting with 100 when updates are pending
+	if ExpectedExit(pkgNum, official, 0, err) {
+		err = nil
+	}
+	if err != nil {
+		return nil, stderr, err
+	}
+	return ParsePendingUpdates(pkgNum, official, string(stdout)), stderr, nil
+}
+
+// Method to let the USER run a package manager unable to target packages, all or nothing
+func ConfirmManager(label string) string {
+	fmt.Println()
+	fmt.Println(Colorize(COLOR_YELLOW, "!!Package manager ["+label+"] cannot upgrade selected packages, so updates are all or nothing"))
+
+	for {
+		answer, err := PromptLine("  Update everything from [" + label + "]? [y]es / [n]o / [a]bort: ")
+		// End of input can not approve anything
+		if err != nil {
+			fmt.Println()
+			return DECISION_ABORT
+		}
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			return DECISION_RUN
+		case "n", "no", "s", "skip":
+			return DECISION_SKIP
+		case "a", "abort", "q", "quit":
+			return DECISION_ABORT
+		default:
+			fmt.Println("!!Please answer y, n or a")
+		}
+	}
+}
+
+// Method to show a checklist of packages, letting the USER deselect some of them
+//
+// Returns the packages still selected, and the decision of the USER on the whole package manager.
+func SelectFromChecklist(label string, packages []string) ([]string, string) {
+	// Initialise variables, selecting everything
+	chosen := make([]bool, len(packages))
+	for i := range chosen {
+		chosen[i] = true
+	}
+
+	for {
+		// Print the checklist
+		fmt.Println()
+		fmt.Println("* Pending updates for [" + label + "]:")
+		for i, name := range packages {
+			mark := " "
+			if chosen[i] {
+				mark = "x"
+			}
+			fmt.Printf("\t%3d [%s] %s\n", i+1, mark, name)
+		}
+
+		answer, err := PromptLine("  Toggle numbers or ranges (e.g. 1 3 5-7), [a]ll, [n]one, [s]kip manager, [q]uit, Enter to continue: ")
+		// End of input can not approve anything
+		if err != nil {
+			fmt.Println()
+			return nil, DECISION_ABORT
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			var selected []string
+			for i, name := range packages {
+				if chosen[i] {
+					selected = append(selected, name)
+				}
+			}
+			return selected, DECISION_RUN
+		case "a", "all":
+			for i := range chosen {
+				chosen[i] = true
+			}
+		case "n", "none":
+			for i := range chosen {
+				chosen[i] = false
+			}
+		case "s", "skip":
+			return nil, DECISION_SKIP
+		case "q", "quit", "abort":
+			return nil, DECISION_ABORT
+		default:
+			toggles, err := ParseToggles(answer, len(packages))
+			if err != nil {
+				fmt.Println("!!", err)
+				continue
+			}
+			for _, index := range toggles {
+				chosen[index] = !chosen[index]
+			}
+		}
+	}
+}
+
+// Method to parse numbers and ranges (1-based) of a checklist into indices
+func ParseToggles(answer string, count int) ([]int, error) {
+	// Initialise variables
+	var indices []int
+
+	for _, token := range strings.FieldsFunc(answer, func(char rune) bool { return char == ' ' || char == ',' }) {
+		first, last, isRange := strings.Cut(token, "-")
+		begin, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, errors.New("invalid selection [" + token + "]")
+		}
+		end := begin
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil {
+				return nil, errors.New("invalid selection [" + token + "]")
+			}
+		}
+		if begin < 1 || end > count || begin > end {
+			return nil, errors.New("selection [" + token + "] out of range 1-" + strconv.Itoa(count))
+		}
+		for number := begin; number <= end; number++ {
+			indices = append(indices, number-1)
+		}
+	}
+	return indices, nil
+}
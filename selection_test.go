@@ -0,0 +1,84 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Tests of the pending updates listed by package managers
+
+package main
+
+// Import packages
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePendingUpdates(t *testing.T) {
+	tests := []struct {
+		name     string
+		pkgNum   int
+		official bool
+		output   string
+		want     []string
+	}{
+		{
+			name:     "apt",
+			pkgNum:   0,
+			official: true,
+			output: "Listing...\n" +
+				"curl/stable-security 7.88.1-10+deb12u8 amd64 [upgradable from: 7.88.1-10+deb12u7]\n" +
+				"tzdata/stable-updates 2025a-0+deb12u1 all [upgradable from: 2024b-0+deb12u1]\n",
+			want: []string{"curl", "tzdata"},
+		},
+		{
+			name:     "dnf",
+			pkgNum:   1,
+			official: true,
+			output: "\n" +
+				"kernel.x86_64                      6.8.9-300.fc40               updates\n" +
+				"python3-libs.x86_64                3.12.3-2.fc40                updates\n",
+			want: []string{"kernel.x86_64", "python3-libs.x86_64"},
+		},
+		{
+			name:     "dnf with wrapped names",
+			pkgNum:   1,
+			official: true,
+			output: "\n" +
+				"NetworkManager.x86_64              1:1.46.0-2.fc40              updates\n" +
+				"texlive-collection-latexrecommended.noarch\n" +
+				"                                   11:svn65512-71.fc40          updates\n" +
+				"vim-enhanced.x86_64                2:9.1.393-1.fc40             updates\n",
+			want: []string{"NetworkManager.x86_64", "texlive-collection-latexrecommended.noarch", "vim-enhanced.x86_64"},
+		},
+		{
+			name:     "yum with obsoleted packages",
+			pkgNum:   4,
+			official: true,
+			output: "bash.x86_64                4.2.46-35.el7_9           updates\n" +
+				"Obsoleting Packages\n" +
+				"grub2.x86_64               1:2.02-0.87.el7_9.14      updates\n",
+			want: []string{"bash.x86_64"},
+		},
+		{
+			name:     "zypper",
+			pkgNum:   3,
+			official: true,
+			output: "S | Repository | Name | Current Version | Available Version | Arch\n" +
+				"--+------------+------+-----------------+-------------------+-------\n" +
+				"v | Main       | curl | 8.6.0-1.1        | 8.7.1-1.1          | x86_64\n",
+			want: []string{"curl"},
+		},
+		{
+			name:     "flatpak",
+			pkgNum:   3,
+			official: false,
+			output:   "app/org.mozilla.firefox/x86_64/stable\nLooking for updates…\n",
+			want:     []string{"app/org.mozilla.firefox/x86_64/stable"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ParsePendingUpdates(test.pkgNum, test.official, test.output); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParsePendingUpdates() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Handling of SIGINT/SIGTERM, and tracking of running package managers
+
+package main
+
+// Import packages
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"os/user"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// // Set once the first SIGINT/SIGTERM is received
+var cancelRequested atomic.Bool
+var cancelChan chan struct{} = make(chan struct{})
+var cancelOnce sync.Once
+
+// // Whether package managers are kept away from terminal signals
+var detachChildren bool = true
+
+// // Time given to commands to stop after timing out, before they are killed
+const TIMEOUT_KILL_GRACE time.Duration = 10 * time.Second
+
+// // Currently running package manager process, if any
+var childMutex sync.Mutex
+var childProcess *os.Process
+
+// // Cleanups run before exiting, e.g. removing temporary files and restoring settings
+var exitHooks []func()
+var exitMutex sync.Mutex
+
+// Method to check if the USER has cancelled the run
+func IsCancelled() bool {
+	return cancelRequested.Load()
+}
+
+// Method to cancel the run: the current step finishes, the rest are skipped
+func RequestCancel() {
+	cancelOnce.Do(func() {
+		cancelRequested.Store(true)
+		close(cancelChan)
+	})
+}
+
+// Method to wait for a duration, returning false early if cancelled by USER
+func CancellableSleep(duration time.Duration) bool {
+	select {
+	case <-time.After(duration):
+		return true
+	case <-cancelChan:
+		return false
+	}
+}
+
+// Method to register a cleanup, run by Exit even when deferred calls are skipped
+//
+// Cleanups must be safe to run more than once.
+func AtExit(hook func()) {
+	exitMutex.Lock()
+	defer exitMutex.Unlock()
+	exitHooks = append(exitHooks, hook)
+}
+
+// Method to run the registered cleanups, the latest first
+func RunExitHooks() {
+	exitMutex.Lock()
+	defer exitMutex.Unlock()
+	for i := len(exitHooks) - 1; i >= 0; i-- {
+		exitHooks[i]()
+	}
+}
+
+// Method to run the registered cleanups, and quit with an exit code
+func Exit(code int) {
+	RunExitHooks()
+	os.Exit(code)
+}
+
+// Method to print the partial report and quit with exit code 130
+func CancelledExit() {
+	PrintFailure("!!Cancelled by USER")
+	runReport.MarkCancelled()
+	runReport.Print()
+	RecordHistory()
+	ExitStatement()
+	Exit(130)
+}
+
+// Method to begin listening for SIGINT/SIGTERM
+//
+// The first signal lets the current package manager step finish and skips the rest,
+// the second signal is forwarded to the running package manager.
+func HandleSignals() {
+	sigChan := make(chan os.Signal, 2)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		// First signal
+		sig := <-sigChan
+		RequestCancel()
+		fmt.Println(Colorize(COLOR_YELLOW, "\n!!Received ["+sig.String()+"], finishing current step and skipping the rest..."))
+		fmt.Println(Colorize(COLOR_YELLOW, "!!Send again to stop the current step"))
+
+		// Any further signals
+		for sig = range sigChan {
+			childMutex.Lock()
+			process := childProcess
+			childMutex.Unlock()
+			switch process {
+			case nil:
+				// Nothing is running, so quit right away
+				CancelledExit()
+			default:
+				fmt.Println(Colorize(COLOR_YELLOW, "\n!!Received ["+sig.String()+"] again, forwarding to running package manager..."))
+				ForwardSignal(process, sig)
+			}
+		}
+	}()
+}
+
+// Options for running a package manager command
+type CommandOptions struct {
+	// Variables added to the current environment
+	Env []string
+	// Whether Env replaces the current environment, instead of adding to it
+	CleanEnv bool
+	// User to run as, instead of the current one
+	Account *user.User
+	// Whether the terminal is attached, so the package manager's own prompts work
+	Interactive bool
+	// Time after which the command is stopped, if not zero
+	Timeout time.Duration
+}
+
+// Method to run a command, keeping track of it so signals can be forwarded
+func RunCommand(options CommandOptions, name string, args ...string) ([]byte, []byte, error) {
+	// Initialise variables
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	command := exec.Command(name, args...)
+	env := options.Env
+	if options.Account != nil {
+		if err := RunAsAccount(command, options.Account); err != nil {
+			return nil, nil, err
+		}
+		env = append(AccountEnv(options.Account), env...)
+	}
+	switch {
+	case options.CleanEnv:
+		command.Env = env
+	case len(env) > 0:
+		command.Env = append(os.Environ(), env...)
+	}
+	switch options.Interactive {
+	case true:
+		// Stderr is still kept, to recognise network errors
+		command.Stdin = os.Stdin
+		command.Stdout = os.Stdout
+		command.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	case false:
+		command.Stdout = &stdout
+		command.Stderr = &stderr
+	}
+	// Keep terminal signals from reaching the package manager directly, unless interactive
+	if detachChildren && !options.Interactive {
+		DetachFromTerminal(command)
+	}
+
+	if err := command.Start(); err != nil {
+		return nil, nil, err
+	}
+	childMutex.Lock()
+	childProcess = command.Process
+	childMutex.Unlock()
+
+	// Stop the command if it runs too long, killing it if it ignores SIGTERM
+	var timedOut atomic.Bool
+	if options.Timeout > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		timer := time.AfterFunc(options.Timeout, func() {
+			timedOut.Store(true)
+			ForwardSignal(command.Process, syscall.SIGTERM)
+			select {
+			case <-done:
+			case <-time.After(TIMEOUT_KILL_GRACE):
+				ForwardSignal(command.Process, os.Kill)
+			}
+		})
+		defer timer.Stop()
+	}
+
+	err := command.Wait()
+	if timedOut.Load() {
+		err = errors.New("timed out after " + options.Timeout.String())
+	}
+
+	childMutex.Lock()
+	childProcess = nil
+	childMutex.Unlock()
+
+	// Keep stderr available to callers, like exec.Cmd.Output() does
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitErr.Stderr = stderr.Bytes()
+	}
+	return stdout.Bytes(), stderr.Bytes(), err
+}
@@ -0,0 +1,28 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Tests of cancellation and cleanups run before exiting
+
+package main
+
+// Import packages
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRunExitHooks(t *testing.T) {
+	// Initialise variables
+	var calls []string
+	saved := exitHooks
+	defer func() { exitHooks = saved }()
+	exitHooks = nil
+
+	AtExit(func() { calls = append(calls, "first") })
+	AtExit(func() { calls = append(calls, "second") })
+	RunExitHooks()
+	RunExitHooks()
+	if want := []string{"second", "first", "second", "first"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+}
@@ -0,0 +1,62 @@
+//go:build !windows
+
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// UNIX-specific process handling
+
+package main
+
+// Import packages
+import (
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// Method to place a command in its own process group, away from terminal signals
+func DetachFromTerminal(command *exec.Cmd) {
+	if command.SysProcAttr == nil {
+		command.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	command.SysProcAttr.Setpgid = true
+}
+
+// Method to run a command as another user (requires running as root)
+func RunAsAccount(command *exec.Cmd, account *user.User) error {
+	// Initialise variables
+	var groups []uint32
+	uid, err := strconv.ParseUint(account.Uid, 10, 32)
+	if err != nil {
+		return err
+	}
+	gid, err := strconv.ParseUint(account.Gid, 10, 32)
+	if err != nil {
+		return err
+	}
+	groupIds, _ := account.GroupIds()
+	for _, groupId := range groupIds {
+		if parsed, err := strconv.ParseUint(groupId, 10, 32); err == nil {
+			groups = append(groups, uint32(parsed))
+		}
+	}
+
+	if command.SysProcAttr == nil {
+		command.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	command.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: groups}
+	command.Dir = account.HomeDir
+	return nil
+}
+
+// Method to forward a signal to a process and its process group
+func ForwardSignal(process *os.Process, sig os.Signal) {
+	// Signal the whole group, so commands started by sudo/doas receive it as well
+	unixSig, ok := sig.(syscall.Signal)
+	if ok && syscall.Kill(-process.Pid, unixSig) == nil {
+		return
+	}
+	process.Signal(sig)
+}
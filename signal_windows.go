@@ -0,0 +1,36 @@
+//go:build windows
+
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Windows-specific process handling
+
+package main
+
+// Import packages
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/user"
+	"syscall"
+)
+
+// Method to place a command in its own process group, away from console Ctrl-C
+func DetachFromTerminal(command *exec.Cmd) {
+	if command.SysProcAttr == nil {
+		command.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	command.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+// Method to run a command as another user (not supported on Windows)
+func RunAsAccount(command *exec.Cmd, account *user.User) error {
+	return errors.New("running as another user is not supported on Windows")
+}
+
+// Method to forward a signal to a process
+func ForwardSignal(process *os.Process, sig os.Signal) {
+	// Windows cannot deliver os.Interrupt to another process, so stop it outright
+	process.Kill()
+}
@@ -9,14 +9,16 @@ package main
 
 // Import packages
 import (
+	"context"
 	"errors"
-	"flag"
 	"fmt"
-	"net/http"
 	"os"
 	"os/exec"
 	"os/user"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -69,10 +71,27 @@ var ALTERNATIVE_PKG_MANAGERS [AL_PKG_NUM]string = [AL_PKG_NUM]string{
 
 // // Critical variables
 var rootUse string
-var debugFlag bool = true
+var debugFlag bool
+
+// // Settings only used by main, set by flags
+var allManualFlag bool
+var altOnlyFlag bool
+var officialOnlyFlag bool
+var yumUpdateFlag bool
+var helpFlag bool
+var versionFlag bool
+var flagsFlag bool
+var warrantyFlag bool
+var maxBandwidthSetting string = "0"
+var configPath string
+var profileName string
 
 // Prints Exit Statement
 func ExitStatement() {
+	// Nothing to advertise in quiet or cron runs
+	if noBannerFlag || quietFlag {
+		return
+	}
 	fmt.Println("\n\t* I hope this program was useful for you!")
 	fmt.Println("\t* Please give this project a star on GitHub!")
 }
@@ -83,46 +102,36 @@ func PrintVersion() {
 	fmt.Println("Update_Full-GO " + LONG_VERSION_NUM)
 }
 
-// Prints Flags statement
-func PrintFlags(verbosity int) {
-	// Print intro
-	if verbosity >= 2 {
-		fmt.Println("There are two types flags available: informational, and functional")
-	}
-	// Informational flags
-	if verbosity >= 1 {
-		fmt.Println("\tInformational (overrides all functional flags):")
-	}
-	fmt.Println("--help     | -h : Prints this help message")
-	fmt.Println("--version  | -v : Prints version statement")
-	fmt.Println("--flags    | -f : Prints all available flags (default verbosity 0)")
-	fmt.Println("--warranty | -w : Prints the warranty seciton from the GNU Public License v3")
-	fmt.Println("--debug    | -d : Prints more verbose technical output for debugging")
-	// Functional flags
-	if verbosity >= 1 {
-		fmt.Println("\tFunctional:")
-	}
-	fmt.Println("--manual-all | -ma : Makes user manually select options presented by")
-	fmt.Println("--alt-only   | -ao : Only updates from alternative package managers (see definition)")
-	fmt.Println("--custom-domain | -cd : Adds an additional domain to test on top of raw.githubusercontent.com")
-	fmt.Println("--official-only | -oo : Only updates from official package managers (see definition)")
-	fmt.Println("--yum-update | -yu : Uses Yum over Dnf, if exists or is applicable")
-}
-
 // Prints Help statement
 func PrintHelp(flagVerbosity int) {
 	// Print version
 	PrintVersion()
 	fmt.Println(" = = =")
 	fmt.Println("This Go script allows for Full updates on a variety of OSs, including Linux, Windows, and other flavours of UNIX")
+	fmt.Println("Usage: update_full [command] [flags]")
+	// Describe available commands
+	fmt.Println("Commands:")
+	PrintCommands()
 	// Begin describing available flags
+	fmt.Println("Flags (long names take --, short aliases take -, values may be attached with =):")
 	PrintFlags(flagVerbosity)
+	fmt.Println("Configuration:")
+	fmt.Println("Every functional flag can be set by its long name in " + CONFIG_SYSTEM_PATH + ", the per-user")
+	fmt.Println("~/.config/update_full/config.toml, or the file in $" + CONFIG_ENV_VAR + " / --config (e.g. official-only = true)")
+	fmt.Println("Tables [host.<hostname>] override options on that host only")
+	fmt.Println("Tables [profile.<name>] bundle options chosen with --profile, and may inherit another (inherits = \"base\")")
+	fmt.Println("Precedence: file < profile < UPDATE_FULL_<OPTION> environment variables (e.g. UPDATE_FULL_MAX_BANDWIDTH=2M) < flags")
 	fmt.Println("Exit codes:")
 	fmt.Println("0: Successful operation of script")
 	fmt.Println("1: Error on behalf of USER")
 	fmt.Println("3: Error on behalf of DEVELOPER")
 	fmt.Println("4: Other Error (environmental, incompatible, etc)")
+	fmt.Println("100: Updates are pending (check command)")
 	fmt.Println("130: Cancelled by USER")
+	fmt.Println("Exit codes of the doctor command:")
+	fmt.Println("0: No problems found (informational findings only)")
+	fmt.Println("1: Warnings found, updates may fail")
+	fmt.Println("2: Critical problems found, updates will likely fail")
 	fmt.Println()
 }
 
@@ -139,77 +148,10 @@ func PrintWarranty() {
 	fmt.Println("COST OF ALL NECESSARY SERVICING, REPAIR OR CORRECTION.")
 }
 
-// Prints values of variables
-func DebugVariablePrint(varName string, isBool bool, boolVar bool, intVar int, stringVar string, errVar error, byteVar []byte, response *http.Response) {
-	switch debugFlag {
-	// End method early to not spend any more time
-	case false:
-		return
-	case true:
-		fmt.Print("DEBUG= ")
-		fmt.Print(varName + ": ")
-		// Define what to print in event of different variables
-		switch isBool {
-		case true:
-		default:
-			fmt.Print(boolVar)
-		}
-		switch intVar {
-		case -1:
-		default:
-			fmt.Print(intVar)
-		}
-		switch stringVar {
-		case "null":
-		default:
-			fmt.Print(stringVar)
-		}
-		switch errVar {
-		case nil:
-		default:
-			fmt.Println(errVar)
-		}
-		switch byteVar {
-		case nil:
-		default:
-			fmt.Print(byteVar)
-		}
-		switch response {
-		case nil:
-		default:
-			fmt.Print(response)
-		}
-
-		fmt.Println()
-	}
-}
-
 // // Extracts Checksum-Checker and runs it
 // func ChecksumCheck() {
 // }
 
-// Method to send a GET request to confirm internet connection
-func UseGetRequest(domain string, errChan chan error) error {
-	response, err := http.Get("https://" + domain)
-	// Print DEBUG statements, if applicable
-	DebugVariablePrint("response", false, false, -1, "null", nil, nil, response)
-	DebugVariablePrint("err", false, false, -1, "null", err, nil, nil)
-	// If successgul, returns nil
-	return err
-}
-
-// Tests internet connectivity using PING (This will eventually be replaced with built-in Go tools)
-func NetworkTest(domain string, errChan chan error) {
-	switch domain {
-	case "N/A":
-		errChan <- nil
-	default:
-		// Temporary solution is using ping before using go's built-in packages
-		// Forward result of UsePing() method to errChan
-		errChan <- UseGetRequest(domain, errChan)
-	}
-}
-
 // Method to abstract creation of a 2D slice
 func ReturnSliceCreator(commandsAmount int, tokenCount int) [][]string {
 	// Make new empty 2D slice
@@ -400,120 +342,361 @@ func PkgManagerActions(pkgNum int, official bool) ([][]string, int, int) {
 	return returnSlices, commandsAmount, tokenCount
 }
 
+// Method to find the non-zero exit codes of a step that do not mean it failed
+//
+// Some steps report what they found through their exit code, e.g. dnf check-update
+// exits with 100 when updates are pending, and swupd check-update with 1 when none are.
+func ExpectedExitCodes(pkgNum int, official bool, step int) []int {
+	switch official {
+	// Official package managers
+	case true:
+		switch pkgNum {
+		// Dnf & Yum: check-update (updates pending)
+		case 1, 4:
+			if step == 0 {
+				return []int{100}
+			}
+		// Zypper: patch-check (patches pending), update & patch (reboot or restart needed)
+		case 3:
+			switch step {
+			case 1:
+				return []int{100, 101}
+			case 2, 3:
+				return []int{102, 103}
+			}
+		// Rpm-Ostree: upgrade --check (no update available)
+		case 5:
+			if step == 1 {
+				return []int{77}
+			}
+		// Clear Linux: check-update (no update available)
+		case 7:
+			if step == 0 {
+				return []int{1}
+			}
+		}
+	}
+	return nil
+}
+
+// Method to check if a step only exited with one of its expected exit codes
+func ExpectedExit(pkgNum int, official bool, step int, err error) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && slices.Contains(ExpectedExitCodes(pkgNum, official, step), exitErr.ExitCode())
+}
+
 // Method to execute updates from specific package managers, depending on the number
 func ExecutePkgManagers(pkgNum int, official bool, manFlag bool) {
+	// Skip package managers left out by the policy (e.g. --managers, --security-only)
+	if skip, reason := ManagerPolicySkip(pkgNum, official); skip {
+		PrintStatus("\t* Skipping package manager [" + PkgManagerName(pkgNum, official) + "] (" + reason + ")")
+		runReport.AddNote("Package manager [" + PkgManagerName(pkgNum, official) + "] skipped (" + reason + ")")
+		return
+	}
+	// Run once for every privilege scope (e.g. Flatpak system and user installations)
+	for _, scope := range PrivilegeScopes(pkgNum, official) {
+		ExecutePkgManagerScope(pkgNum, official, manFlag, scope)
+	}
+}
+
+// Method to execute updates from a package manager in a single privilege scope
+func ExecutePkgManagerScope(pkgNum int, official bool, manFlag bool, scope PrivilegeScope) {
 	// Initialise variables
 	var err error
 	var stdout []byte
+	var stderr []byte
 	var finalActionSlice []string
+	var deselected bool
+	var selected bool
 	// Retrieve package manager-specific data
 	pkgManActions, actionCount, tokenCount := PkgManagerActions(pkgNum, official)
+	// Nothing is run for package managers without actions (e.g. Pacman, Xbps)
+	if actionCount == 0 {
+		logger.Debug("no actions for package manager", "manager", PkgManagerName(pkgNum, official))
+		return
+	}
+
+	// Only elevated scopes use sudo/doas
+	var escalation string
+	switch scope.Elevated {
+	case true:
+		escalation = rootUse
+	}
 
-	// DEBUG statement to see if official manager is used
-	DebugVariablePrint("official", true, official, -1, "null", nil, nil, nil)
+	// Retrieve bandwidth limits, if any
+	bandwidthArgs, bandwidthEnv, throttled := BandwidthArgs(pkgNum, official)
+	// Remove temporary files and restore settings (e.g. Snap's rate limit) after this package manager
+	defer RunExitHooks()
+
+	// Define what type of package managers to use
+	var pkgManToUse string
+	switch official {
+	case true:
+		pkgManToUse = OFFICIAL_PKG_MANAGERS[pkgNum]
+	case false:
+		pkgManToUse = ALTERNATIVE_PKG_MANAGERS[pkgNum]
+	}
+	// Name used in the report, including the scope
+	pkgManLabel := pkgManToUse
+	if scope.Label != "" {
+		pkgManLabel += " (" + scope.Label + ")"
+	}
+
+	// Resolve the package manager (and sudo/doas and env, if escalating) to trusted paths
+	scoped := ScopedManager{Scope: scope, Escalation: escalation}
+	scoped.Path, err = ResolveManager(pkgManToUse, scope.Elevated, scope.Account)
+	if err == nil && escalation != "" {
+		scoped.EscalationPath, err = ResolveManager(escalation, true, nil)
+	}
+	if err == nil && escalation != "" {
+		scoped.EnvPath, err = ResolveManager("env", true, nil)
+	}
+	if err == nil && escalation != "" {
+		scoped.ShellPath, err = ResolveManager("sh", true, nil)
+	}
+	if err != nil {
+		PrintFailure("!!Package manager ["+pkgManLabel+"] rejected:", err)
+		logger.Error("package manager rejected", "manager", pkgManLabel, "err", err)
+		runReport.AddStep(pkgManLabel, []string{pkgManToUse}, STEP_FAILED, err, 0)
+		return
+	}
+	logger.Debug("resolved package manager", "manager", pkgManLabel, "official", official, "path", scoped.Path, "escalation", escalation, "escalation_path", scoped.EscalationPath)
+
+	// Home of elevated commands
+	scoped.Home = RootHome()
+	if escalation == "" && os.Geteuid() != 0 {
+		scoped.Home = os.Getenv("HOME")
+	}
+
+	// Apply bandwidth limits kept by the package manager itself (e.g. Snap)
+	if !IsCancelled() && !ApplyManagerBandwidth(scoped, pkgNum, official) {
+		throttled = false
+	}
+
+	// Check free disk space before changing anything
+	if !IsCancelled() {
+		// The download estimate may use the network, so it needs the proxy and bandwidth settings
+		proxyArgs, proxyEnv, _ := ProxyArgs(pkgNum, official)
+		err = DiskPreflight(scoped, pkgNum, official, pkgManLabel, manFlag, append(proxyArgs, bandwidthArgs...), append(proxyEnv, bandwidthEnv...))
+		switch {
+		case err != nil && IsCancelled():
+			runReport.AddStep(pkgManLabel, []string{"disk preflight"}, STEP_SKIPPED, err, 0)
+			return
+		case err != nil:
+			PrintFailure("!!Package manager ["+pkgManLabel+"] skipped:", err)
+			logger.Error("disk preflight failed", "manager", pkgManLabel, "policy", diskPolicy, "err", err)
+			runReport.AddStep(pkgManLabel, []string{"disk preflight"}, STEP_FAILED, err, 0)
+			return
+		}
+	}
+
+	// Package managers unable to target packages are updated all-or-nothing, if selecting
+	selectStep, selectable := SelectionStep(pkgNum, official)
+	if selectFlag && !selectable && !IsCancelled() {
+		switch ConfirmManager(pkgManLabel) {
+		case DECISION_SKIP:
+			deselected = true
+		case DECISION_ABORT:
+			RequestCancel()
+		}
+	}
 
 	// // Iterate, adding command arguments as needed
 	for i := 0; i < actionCount; i++ {
 		// Clear finalActionSlice for next iteration
 		finalActionSlice = []string{}
 
-		// Define what type of package managers to use
-		var pkgManToUse string
-		switch official {
-		case true:
-			pkgManToUse = OFFICIAL_PKG_MANAGERS[pkgNum]
+		// Report package managers that cannot be throttled, once
+		if !throttled && i == 0 {
+			PrintWarning("!!Package manager [" + pkgManToUse + "] cannot be throttled, --max-bandwidth is ignored")
+			runReport.AddNote("Package manager [" + pkgManToUse + "] was NOT throttled (unsupported)")
+		}
+
+		// Find proxy settings, reporting package managers ignoring the proxy once
+		proxyArgs, stepEnv, proxied := ProxyArgs(pkgNum, official)
+		stepEnv = append(stepEnv, bandwidthEnv...)
+		if !proxied && i == 0 {
+			PrintWarning("!!Package manager [" + pkgManToUse + "] ignores --proxy, configure its own proxy (e.g. snap set system proxy.https=...)")
+			runReport.AddNote("Package manager [" + pkgManToUse + "] was NOT proxied (unsupported)")
+		}
+
+		// Find assume-yes options, unless the USER approves manually
+		var yesPrefix []string
+		var yesSuffix []string
+		switch manFlag {
 		case false:
-			pkgManToUse = ALTERNATIVE_PKG_MANAGERS[pkgNum]
+			var yesEnv []string
+			yesPrefix, yesSuffix, yesEnv = NonInteractiveArgs(pkgNum, official, i)
+			stepEnv = append(stepEnv, yesEnv...)
 		}
 
-		// Add more items to finalActionSlice[] slice, as needed
-		switch rootUse {
-		case "": // If root, no sudo/doas needed
-		default:
-			finalActionSlice = append(finalActionSlice, pkgManToUse)
+		// Find options to work from local caches, if offline
+		var offlinePrefix []string
+		var offlineSuffix []string
+		var offlineRun bool = true
+		switch offlineFlag {
+		case true:
+			offlinePrefix, offlineSuffix, offlineRun = OfflineArgs(pkgNum, official, i)
 		}
 
-		// Add specific actions for the package manager
+		// Find specific actions for the package manager
+		var actions []string
 		for j := 0; j < tokenCount; j++ {
 			switch pkgManActions[i][j] {
 			case "": // Skip empty lines
 			default:
-				// Print debug statement if needed
-				switch debugFlag {
-				case true:
-					fmt.Print(" " + pkgManActions[i][j])
-				}
-
-				finalActionSlice = append(finalActionSlice, pkgManActions[i][j])
+				actions = append(actions, pkgManActions[i][j])
 			}
 		}
 
-		// Add "-y" flags as needed
-		switch manFlag {
-		case false:
-			// Add "-y" flag
-			finalActionSlice = append(finalActionSlice, "-y" /*manualResponse*/)
-
-			// Remove "-y" flag according to defined exceptions
-			// Some commands accept empty "" flags, while others do not.
-			//  In this case, it is better to just remove the last flag entirely
-			switch official {
-			// Official package managers
-			case true:
-				switch pkgNum {
-				// Apt package manager
-				case 0:
-					switch i {
-					case 0: // update
-						finalActionSlice = finalActionSlice[:len(finalActionSlice)-1]
-					}
-				// Zypper package manager
-				case 3:
-					switch i {
-					case 0, 1, 4: // list-updates
-						finalActionSlice = finalActionSlice[:len(finalActionSlice)-1]
-					}
-				// transactional-update Immutable package manager
-				case 2, 5, 6, 14:
-					// finalActionSlice = finalActionSlice[:len(finalActionSlice)-1]
-					finalActionSlice = finalActionSlice[:len(finalActionSlice)-1]
-				}
-			// Alternative package managers
-			case false:
-				switch pkgNum {
-				// Brew package manager
-				case 0:
-					finalActionSlice = finalActionSlice[:len(finalActionSlice)-1]
-				// Snap package manager
-				case 1:
-					switch i {
-					case 0: // refresh
-						finalActionSlice = finalActionSlice[:len(finalActionSlice)-1]
+		// Limit the step to security updates, if asked
+		var securityRun bool = true
+		switch securityOnlyFlag {
+		case true:
+			var securityArgs []string
+			securityArgs, securityRun, _ = SecurityArgs(pkgNum, official, i)
+			actions = append(actions, securityArgs...)
+		}
+
+		// Let the USER pick pending updates, and only upgrade those, if selecting
+		var nothingSelected bool
+		if selectFlag && selectable && i == selectStep && offlineRun && securityRun && !deselected && !IsCancelled() {
+			packages, targeted, decision := SelectPendingUpdates(scoped, pkgNum, official, pkgManLabel, append(append([]string{}, scope.Args...), proxyArgs...), stepEnv)
+			switch decision {
+			case DECISION_SKIP:
+				deselected = true
+			case DECISION_ABORT:
+				RequestCancel()
+			default:
+				if targeted {
+					selected = true
+					nothingSelected = len(packages) == 0
+					actions = TargetedUpgradeArgs(pkgNum, official, packages)
+					if securityOnlyFlag {
+						securityArgs, _, _ := SecurityArgs(pkgNum, official, i)
+						actions = append(actions, securityArgs...)
 					}
 				}
 			}
 		}
 
-		// DEBUG statement to check critical variables
-		DebugVariablePrint("rootUse", false, false, -1, rootUse, nil, nil, nil)
-		DebugVariablePrint("manualResponse", false, false, -1, finalActionSlice[len(finalActionSlice)-1], nil, nil, nil)
-		DebugVariablePrint("Slice LENGTH", false, false, len(finalActionSlice), "null", nil, nil, nil)
+		// Add items to finalActionSlice[] slice, as needed
+		finalActionSlice = append(finalActionSlice, yesPrefix...)
+		finalActionSlice = append(finalActionSlice, offlinePrefix...)
+		finalActionSlice = append(finalActionSlice, actions...)
+		finalActionSlice = append(finalActionSlice, offlineSuffix...)
+		finalActionSlice = append(finalActionSlice, scope.Args...)
+		finalActionSlice = append(finalActionSlice, proxyArgs...)
+		finalActionSlice = append(finalActionSlice, bandwidthArgs...)
+		finalActionSlice = append(finalActionSlice, yesSuffix...)
 
-		// Execute commands, depending on rootUse
-		switch rootUse {
-		case "":
-			stdout, err = exec.Command(pkgManToUse, finalActionSlice...).Output()
-		default:
-			stdout, err = exec.Command(rootUse, finalActionSlice...).Output()
+		// Define full command, depending on escalation
+		commandName, commandArgs, options := scoped.Command(finalActionSlice, stepEnv, manFlag)
+		fullCommand := append([]string{commandName}, commandArgs...)
+		options.Timeout = stepTimeout
+		stepLogger := logger.With("manager", pkgManLabel, "step", i)
+		stepLogger.Debug("planned step", "command", fullCommand)
+
+		// Skip remaining steps if cancelled, or deselected by USER
+		if IsCancelled() {
+			runReport.AddStep(pkgManLabel, fullCommand, STEP_SKIPPED, nil, 0)
+			continue
+		}
+		if deselected {
+			runReport.AddStep(pkgManLabel, fullCommand, STEP_SKIPPED, errors.New("deselected by USER"), 0)
+			continue
 		}
 
-		// Get error messages, and work accordingly
-		switch err {
-		case nil:
-			fmt.Println(string(stdout))
-		default:
-			fmt.Println(string(stdout))
-			fmt.Println(err)
+		// Skip steps needing the network, if offline
+		if !offlineRun {
+			PrintStatus("* Skipping [" + RedactSecrets(strings.Join(fullCommand, " ")) + "] (needs the network)")
+			runReport.AddStep(pkgManLabel, fullCommand, STEP_SKIPPED, errors.New("needs the network (offline mode)"), 0)
+			continue
+		}
+
+		// Skip steps updating more than security fixes, if asked
+		if !securityRun {
+			PrintStatus("* Skipping [" + RedactSecrets(strings.Join(fullCommand, " ")) + "] (security updates only)")
+			runReport.AddStep(pkgManLabel, fullCommand, STEP_SKIPPED, errors.New("security updates only"), 0)
+			continue
+		}
+
+		// Skip upgrades of every package, or of no package, once the USER selected packages
+		if nothingSelected {
+			PrintStatus("* No packages selected for [" + pkgManLabel + "]")
+			runReport.AddStep(pkgManLabel, fullCommand, STEP_SKIPPED, errors.New("no packages selected"), 0)
+			continue
+		}
+		if selected && IsBulkUpgradeStep(pkgNum, official, i) {
+			PrintStatus("* Skipping [" + RedactSecrets(strings.Join(fullCommand, " ")) + "] (would upgrade unselected packages)")
+			runReport.AddStep(pkgManLabel, fullCommand, STEP_SKIPPED, errors.New("would upgrade unselected packages"), 0)
+			continue
+		}
+
+		// Let the USER run, skip or edit each step, or abort, if manual
+		switch manFlag {
+		case true:
+			decision, editedArgs := ConfirmStep(pkgManLabel, pkgManToUse, escalation, finalActionSlice)
+			switch decision {
+			case DECISION_SKIP:
+				runReport.AddStep(pkgManLabel, fullCommand, STEP_SKIPPED, errors.New("skipped by USER"), 0)
+				continue
+			case DECISION_ABORT:
+				RequestCancel()
+				runReport.AddStep(pkgManLabel, fullCommand, STEP_SKIPPED, errors.New("aborted by USER"), 0)
+				continue
+			}
+			finalActionSlice = editedArgs
+			commandName, commandArgs, options = scoped.Command(finalActionSlice, stepEnv, manFlag)
+			fullCommand = append([]string{commandName}, commandArgs...)
+			options.Timeout = stepTimeout
+			stepLogger.Info("step edited by USER", "command", fullCommand)
+		}
+
+		// Execute commands, retrying network-bound steps on network errors
+		networkStep := IsNetworkStep(pkgNum, official, i)
+		for attempt := 1; ; attempt++ {
+			stepBegin := time.Now()
+			stepLogger.Info("running step", "command", fullCommand, "attempt", attempt)
+			PrintVerbose("* Running: " + RedactSecrets(strings.Join(fullCommand, " ")))
+			stdout, stderr, err = RunCommand(options, commandName, commandArgs...)
+			if ExpectedExit(pkgNum, official, i, err) {
+				stepLogger.Debug("expected exit code", "command", fullCommand, "err", err)
+				err = nil
+			}
+			PrintOutput(stdout)
+			if len(stderr) > 0 && !manFlag {
+				PrintVerbose(strings.TrimSpace(string(stderr)))
+			}
+
+			// Retry if the network is at fault, attempts remain, and USER has not cancelled
+			if networkStep && attempt < retryAttempts && !IsCancelled() && IsNetworkFailure(pkgNum, official, err, string(stderr)) {
+				switch err {
+				case nil:
+					err = errors.New("repositories failed to refresh")
+				}
+				PrintWarning(err)
+				runReport.AddAttempt(pkgManLabel, fullCommand, STEP_RETRIED, attempt, err, time.Since(stepBegin))
+				stepLogger.Warn("network error, retrying", "command", fullCommand, "attempt", attempt, "delay", RetryBackoff(attempt), "duration", time.Since(stepBegin), "err", err)
+				PrintWarning("!!Network error detected, retrying in", RetryBackoff(attempt), "(attempt", attempt+1, "of", retryAttempts, ")")
+				if CancellableSleep(RetryBackoff(attempt)) {
+					continue
+				}
+				// Cancelled while waiting, this attempt is already reported
+				break
+			}
+
+			// Get error messages, and work accordingly
+			switch err {
+			case nil:
+				stepLogger.Info("step finished", "command", fullCommand, "attempt", attempt, "status", STEP_OK, "duration", time.Since(stepBegin))
+				runReport.AddAttempt(pkgManLabel, fullCommand, STEP_OK, attempt, nil, time.Since(stepBegin))
+			default:
+				PrintFailure("!!Step of [" + pkgManLabel + "] failed: " + err.Error())
+				stepLogger.Error("step failed", "command", fullCommand, "attempt", attempt, "status", STEP_FAILED, "duration", time.Since(stepBegin), "err", err, "stderr", strings.TrimSpace(string(stderr)))
+				runReport.AddAttempt(pkgManLabel, fullCommand, STEP_FAILED, attempt, err, time.Since(stepBegin))
+			}
+			break
 		}
 	}
 }
@@ -531,29 +714,27 @@ func PkgManCheck(pkgNum int, official bool) bool {
 		stdout, err = exec.Command(ALTERNATIVE_PKG_MANAGERS[pkgNum], "--help").Output()
 	}
 	// Return true or false based off of result
+	var manager string
+	switch official {
+	case true:
+		manager = OFFICIAL_PKG_MANAGERS[pkgNum]
+	case false:
+		manager = ALTERNATIVE_PKG_MANAGERS[pkgNum]
+	}
 	switch err {
 	case nil:
-		switch official {
-		case true:
-			DebugVariablePrint("FOUND PACKAGE MANAGER", false, false, -1, OFFICIAL_PKG_MANAGERS[pkgNum], nil, nil, nil)
-		case false:
-			DebugVariablePrint("FOUND PACKAGE MANAGER", false, false, -1, ALTERNATIVE_PKG_MANAGERS[pkgNum], nil, nil, nil)
-		}
+		logger.Debug("found package manager", "manager", manager, "official", official)
 		return true
 	default:
-		DebugVariablePrint("err", false, false, -1, "null", err, nil, nil)
-		DebugVariablePrint("stdout", false, false, -1, string(stdout), nil, nil, nil)
+		logger.Debug("package manager not found", "manager", manager, "official", official, "err", err, "stdout", string(stdout))
 		return false
 	}
 }
 
 // Method to check for existance of package managers
 func PkgManBegin(aoFlag bool, ooFlag bool, manFlag bool, yFlag bool) error {
-	// DEBUG statement to print parameter Statuses
-	DebugVariablePrint("AOFLAG", true, aoFlag, -1, "null", nil, nil, nil)
-	DebugVariablePrint("OOFlag", true, ooFlag, -1, "null", nil, nil, nil)
-	DebugVariablePrint("MANFLAG", true, manFlag, -1, "null", nil, nil, nil)
-	DebugVariablePrint("YUMFLAG", true, yFlag, -1, "null", nil, nil, nil)
+	// Log parameter statuses
+	logger.Debug("checking package managers", "alt_only", aoFlag, "official_only", ooFlag, "manual", manFlag, "yum", yFlag)
 
 	// Initialise varibles
 	var typeCheck int = 0
@@ -571,12 +752,12 @@ func PkgManBegin(aoFlag bool, ooFlag bool, manFlag bool, yFlag bool) error {
 
 	// Loop through package managers, official first, then alternative
 	for i := typeCheck; i < 2; i++ {
-		// // DEBUG statement to print i status
-		DebugVariablePrint("i", false, false, i, "null", nil, nil, nil)
+		// Stop checking for package managers if cancelled by USER
+		if IsCancelled() {
+			return nil
+		}
 		// pkgLoop = 0
 		for i2 := 0; i2 < typeToIterate+1; i2++ {
-			// // DEBUG statement to print i2 status
-			DebugVariablePrint("i2", false, false, i2, "null", nil, nil, nil)
 			// Use specific actions for different managers, when applicable
 			switch i2 {
 			// In case of missing official package manager, return error
@@ -590,6 +771,12 @@ func PkgManBegin(aoFlag bool, ooFlag bool, manFlag bool, yFlag bool) error {
 				}
 			default:
 				result := PkgManCheck(i2, officialPkgMan)
+				// Skip package managers needing ROOT privileges, if user-only
+				if result && userOnlyFlag && !IsUserLevelManager(i2, officialPkgMan) {
+					PrintStatus("\t* Skipping package manager [" + ALTERNATIVE_PKG_MANAGERS[i2] + "] (needs ROOT privileges)")
+					runReport.AddNote("Package manager [" + ALTERNATIVE_PKG_MANAGERS[i2] + "] skipped (needs ROOT privileges)")
+					continue
+				}
 				switch result {
 				case true:
 					// Add exception for Yum, if Dnf exists
@@ -601,25 +788,31 @@ func PkgManBegin(aoFlag bool, ooFlag bool, manFlag bool, yFlag bool) error {
 							result := PkgManCheck(4, officialPkgMan)
 							switch result {
 							case true:
-								DebugVariablePrint("USING YUM over DNF", false, false, 01, "null", nil, nil, nil)
+								logger.Info("using yum over dnf")
 								i2 = 4
 							case false:
-								fmt.Println("-yu / --yum-update flag used, but YUM does NOT exist")
-								fmt.Println("Using DNF instead")
+								PrintWarning("-yu / --yum-update flag used, but YUM does NOT exist")
+								PrintWarning("Using DNF instead")
 							}
 						}
 					}
 
 					// Execute package managers
-					fmt.Print("\t* Using package manager [")
 					switch officialPkgMan {
 					case true:
-						fmt.Print(OFFICIAL_PKG_MANAGERS[i2])
+						PrintStatus("\t* Using package manager [" + OFFICIAL_PKG_MANAGERS[i2] + "] on " + OS_TYPE)
 					case false:
-						fmt.Print(ALTERNATIVE_PKG_MANAGERS[i2])
+						PrintStatus("\t* Using package manager [" + ALTERNATIVE_PKG_MANAGERS[i2] + "] on " + OS_TYPE)
+					}
+					// Keep the system from sleeping or shutting down while official package managers run
+					switch officialPkgMan {
+					case true:
+						inhibitor := InhibitSleep("Updating packages with " + OFFICIAL_PKG_MANAGERS[i2])
+						ExecutePkgManagers(i2, officialPkgMan, manFlag)
+						inhibitor.Release()
+					case false:
+						ExecutePkgManagers(i2, officialPkgMan, manFlag)
 					}
-					fmt.Println("] on " + OS_TYPE)
-					ExecutePkgManagers(i2, officialPkgMan, manFlag)
 
 					// If official package manager, break loop after execution
 					switch officialPkgMan {
@@ -650,7 +843,7 @@ func PkgManBegin(aoFlag bool, ooFlag bool, manFlag bool, yFlag bool) error {
 }
 
 // Define actions to take based on flags
-func ActionsForFlags(aoFlag bool, ooFlag bool, cdFlag string) error {
+func ActionsForFlags(aoFlag bool, ooFlag bool, cdTargets []string) error {
 	// Initialise variables
 	var err error
 
@@ -659,45 +852,33 @@ func ActionsForFlags(aoFlag bool, ooFlag bool, cdFlag string) error {
 		return errors.New("incompatible arguments [-ao && -oo]")
 	}
 
-	// Begin network test
-	// // Create a new channel that funnels errors
-	errChan := make(chan error, 2)
-	fmt.Println("* Testing connection to [raw.githubusercontent.com]")
-	switch cdFlag {
-	case "N/A":
-		NetworkTest("raw.githubusercontent.com", errChan)
-		if err = <-errChan; err != nil {
-			fmt.Println("!!Error when testing domain [raw.githubusercontent.com]...")
-			return err
-		} else {
-			fmt.Println("* Network test with domain [raw.githubusercontent.com]  successful!")
+	// Skip all network tests if offline
+	switch offlineFlag {
+	case true:
+		PrintStatus("* Offline mode, skipping network tests")
+		return nil
+	}
+
+	// Begin network test, concurrently checking every target
+	targets := append([]string{DEFAULT_NETWORK_TARGET}, cdTargets...)
+	PrintStatus("* Testing connection to [" + strings.Join(targets, "], [") + "]")
+	results := NewConnectivityChecker().CheckTargets(context.Background(), targets)
+	for _, result := range results {
+		PrintTargetResult(result)
+		// Remember the first failure
+		if result.Verdict() != VERDICT_OK && err == nil {
+			err = errors.New("network test with domain [" + result.Target + "] failed: " + result.Verdict())
 		}
-	default:
-		fmt.Println("* Testing connection to [" + cdFlag + "]")
-		// Concurrently run two instances of NetworkTest() method
-		go NetworkTest("raw.githubusercontent.com", errChan)
-		go NetworkTest(cdFlag, errChan)
-		// // Loop through both errChannels
-		for i := 0; i < 2; i++ {
-			if err = <-errChan; err != nil {
-				// Define specific error message
-				switch i {
-				case 0:
-					fmt.Println("!!Error when testing domain [raw.githubusercontent.com]")
-					return err
-				case 1:
-					fmt.Println("!!Error when testing domain [" + cdFlag + "]")
-					return err
-				}
-			} else {
-				// Define specific success message
-				switch i {
-				case 0:
-					fmt.Println("* Network test with domain [raw.githubusercontent.com]  successful!")
-				case 1:
-					fmt.Println("* Network test with domain [" + cdFlag + "] successful!")
-				}
-			}
+	}
+	if err != nil {
+		return err
+	}
+
+	// Check the repositories actually configured, unless skipped
+	switch skipRepoCheck {
+	case false:
+		if err = RepositoryCheck(aoFlag, ooFlag); err != nil {
+			return err
 		}
 	}
 
@@ -715,47 +896,24 @@ func IsExecutorRoot(username string) (string, error) {
 		switch username {
 		// If username is "root", then simply continue
 		case "root":
-			fmt.Println("* Script is run as root")
+			PrintStatus("* Script is run as root")
+			logger.Info("running as root")
 			return "", nil
-		// Otherwise, check for sudo or doit
+		// Otherwise, find a usable privilege escalation method
 		default:
-			fmt.Println("* Script not executed as root, checking if user " + username + " has sudo/doas permission...")
-			// Check SUDO
-			stdout, err := exec.Command("sh", "-c", "sudo -l | grep ALL").Output()
-			switch err {
-			case nil:
-				switch string(stdout) {
-				case "":
-					fmt.Println("* no sudo detected..")
-					DebugVariablePrint("stdout", false, false, -1, string(stdout), nil, nil, nil)
-					DebugVariablePrint("err", false, false, -1, "null", err, nil, nil)
-				default:
-					fmt.Println("\t* User " + username + " has sudo permissions, continueing...")
-					return "sudo", nil
-				}
-			default:
-				fmt.Println(err)
-				fmt.Println("* sudo not found...")
-				fmt.Println(stdout)
+			PrintStatus("* Script not executed as root, checking privilege escalation for user " + username + "...")
+			probe, err := NewEscalationProber(username).Choose(escalateFlag)
+			if err != nil {
+				logger.Error("no privilege escalation available", "user", username, "escalate", escalateFlag, "err", err)
+				return "ERROR", err
 			}
-			// Check DOAS
-			err = exec.Command("doas").Run()
-			switch err {
-			case nil:
-				stdout, err = exec.Command("sh", "-c", "groups $(whoami) | grep wheel").Output()
-				switch string(stdout) {
-				case "":
-					fmt.Println("* no doas detected..")
-					DebugVariablePrint("stdout", false, false, -1, string(stdout), nil, nil, nil)
-					DebugVariablePrint("err", false, false, -1, "null", err, nil, nil)
-				default:
-					fmt.Println("\t* User " + username + " has doas permissions, continueing...")
-					return "doas", nil
-				}
+			PrintEscalation(probe)
+			logger.Info("privilege escalation chosen", "user", username, "method", probe.Method, "needs_password", probe.NeedsPassword, "reason", probe.Reason)
+			if err = PrepareEscalation(probe); err != nil {
+				logger.Error("privilege escalation failed", "user", username, "method", probe.Method, "err", err)
+				return "ERROR", err
 			}
-
-			// By this point, neither are found, so throw an error to be caught later
-			return "ERROR", errors.New("missing root priviledges")
+			return probe.Method, nil
 		}
 	}
 }
@@ -776,70 +934,114 @@ func ClearScreen() {
 	clearCommand.Run()
 }
 
+// Method to register every flag, once, under its long name and aliases
+func RegisterFlags() {
+	// // Informational flags
+	RegisterFlag(FlagDef{Name: "help", Aliases: []string{"h"}, Usage: "Prints this help message", Value: BoolValue{&helpFlag}, Group: FLAG_GROUP_INFO, NoConfig: true})
+	RegisterFlag(FlagDef{Name: "version", Aliases: []string{"v"}, Usage: "Prints version statement", Value: BoolValue{&versionFlag}, Group: FLAG_GROUP_INFO, NoConfig: true})
+	RegisterFlag(FlagDef{Name: "flags", Aliases: []string{"f"}, Usage: "Prints all available flags", Value: BoolValue{&flagsFlag}, Group: FLAG_GROUP_INFO, NoConfig: true})
+	RegisterFlag(FlagDef{Name: "warranty", Aliases: []string{"w"}, Usage: "Prints the warranty section from the GNU Public License v3", Value: BoolValue{&warrantyFlag}, Group: FLAG_GROUP_INFO, NoConfig: true})
+	// // Updating
+	RegisterFlag(FlagDef{Name: "manual-all", Aliases: []string{"ma"}, Usage: "Asks to run, skip, edit or abort each step, and lets package managers prompt the user", Value: BoolValue{&allManualFlag}, Group: FLAG_GROUP_UPDATE})
+	RegisterFlag(FlagDef{Name: "select", Usage: "Lists pending updates, and upgrades only the packages kept selected", Value: BoolValue{&selectFlag}, Group: FLAG_GROUP_UPDATE})
+	RegisterFlag(FlagDef{Name: "alt-only", Aliases: []string{"ao"}, Usage: "Only updates from alternative package managers", Value: BoolValue{&altOnlyFlag}, Group: FLAG_GROUP_UPDATE})
+	RegisterFlag(FlagDef{Name: "official-only", Aliases: []string{"oo"}, Usage: "Only updates from official package managers", Value: BoolValue{&officialOnlyFlag}, Group: FLAG_GROUP_UPDATE})
+	RegisterFlag(FlagDef{Name: "yum-update", Aliases: []string{"yu"}, Usage: "Uses Yum over Dnf, if it exists", Value: BoolValue{&yumUpdateFlag}, Group: FLAG_GROUP_UPDATE})
+	RegisterFlag(FlagDef{Name: "offline", Usage: "Skips network tests, and updates only from local caches", Value: BoolValue{&offlineFlag}, Group: FLAG_GROUP_UPDATE})
+	RegisterFlag(FlagDef{Name: "retries", ArgName: "<n>", Usage: "Attempts for network-bound steps failing on network errors", Value: IntValue{&retryAttempts}, Group: FLAG_GROUP_UPDATE})
+	RegisterFlag(FlagDef{Name: "retry-delay", ArgName: "<duration>", Usage: "Delay before the first retry, doubled for each retry", Value: DurationValue{&retryDelay}, Group: FLAG_GROUP_UPDATE})
+	RegisterFlag(FlagDef{Name: "retry-max-delay", ArgName: "<duration>", Usage: "Maximum delay between retries", Value: DurationValue{&retryMaxDelay}, Group: FLAG_GROUP_UPDATE})
+	RegisterFlag(FlagDef{Name: "step-timeout", ArgName: "<duration>", Usage: "Stops package manager steps and hooks running longer than this", Value: DurationValue{&stepTimeout}, Group: FLAG_GROUP_UPDATE})
+	// // Network
+	RegisterFlag(FlagDef{Name: "custom-domain", Aliases: []string{"cd"}, ArgName: "<domain>", Usage: "Adds a domain to test on top of " + DEFAULT_NETWORK_TARGET + " (repeatable)", Value: StringListFlag{&networkTargets}, Group: FLAG_GROUP_NETWORK})
+	RegisterFlag(FlagDef{Name: "network-timeout", ArgName: "<duration>", Usage: "Timeout for each connectivity check", Value: DurationValue{&networkTimeout}, Group: FLAG_GROUP_NETWORK})
+	RegisterFlag(FlagDef{Name: "captive-portal-url", ArgName: "<url>", Usage: "URL expected to answer 204 without a captive portal (empty disables)", Value: StringValue{&captivePortalURL}, Group: FLAG_GROUP_NETWORK})
+	RegisterFlag(FlagDef{Name: "skip-repo-check", Usage: "Skips testing the repositories configured for each package manager", Value: BoolValue{&skipRepoCheck}, Group: FLAG_GROUP_NETWORK})
+	RegisterFlag(FlagDef{Name: "proxy", ArgName: "<url>", Usage: "Proxy used for network tests and every package manager (except Snap)", Value: StringValue{&proxyURL}, Group: FLAG_GROUP_NETWORK})
+	RegisterFlag(FlagDef{Name: "max-bandwidth", ArgName: "<rate>", Usage: "Limits download bandwidth where supported (e.g. 512K, 2M)", Value: StringValue{&maxBandwidthSetting}, Group: FLAG_GROUP_NETWORK})
+	// // Privileges
+	RegisterFlag(FlagDef{Name: "escalate", ArgName: "<method>", Usage: "Privilege escalation: auto, sudo, doas, run0, pkexec or none", Value: StringValue{&escalateFlag}, Group: FLAG_GROUP_PRIVILEGE, Choices: EscalationChoices})
+	RegisterFlag(FlagDef{Name: "user-only", Usage: "Only updates user-level package managers, without ROOT privileges", Value: BoolValue{&userOnlyFlag}, Group: FLAG_GROUP_PRIVILEGE})
+	// // Policy
+	RegisterFlag(FlagDef{Name: "managers", ArgName: "<names>", Usage: "Only uses these package managers (repeatable, or comma-separated)", Value: StringListFlag{&managersFilter}, Group: FLAG_GROUP_POLICY, Choices: ManagerChoices})
+	RegisterFlag(FlagDef{Name: "security-only", Usage: "Only applies security updates (dnf, yum, zypper), skipping other managers", Value: BoolValue{&securityOnlyFlag}, Group: FLAG_GROUP_POLICY})
+	RegisterFlag(FlagDef{Name: "reboot", ArgName: "<policy>", Usage: "Reboots after a successful run: never, if-required or always", Value: StringValue{&rebootPolicy}, Group: FLAG_GROUP_POLICY, Choices: RebootChoices})
+	RegisterFlag(FlagDef{Name: "min-free-space", ArgName: "<size>", Usage: "Free space needed where package managers write, on top of downloads (e.g. 500M, 2G)", Value: StringValue{&minFreeSpaceSetting}, Group: FLAG_GROUP_POLICY})
+	RegisterFlag(FlagDef{Name: "min-free-boot", ArgName: "<size>", Usage: "Free space needed on /boot", Value: StringValue{&minFreeBootSetting}, Group: FLAG_GROUP_POLICY})
+	RegisterFlag(FlagDef{Name: "min-free-inodes", ArgName: "<percent>", Usage: "Free inodes needed where package managers write, in percent", Value: IntValue{&minFreeInodes}, Group: FLAG_GROUP_POLICY})
+	RegisterFlag(FlagDef{Name: "disk-policy", ArgName: "<policy>", Usage: "When short of disk space: abort (skip the manager), clean (its caches first) or warn", Value: StringValue{&diskPolicy}, Group: FLAG_GROUP_POLICY, Choices: DiskPolicyChoices})
+	RegisterFlag(FlagDef{Name: "min-battery", ArgName: "<percent>", Usage: "Refuses to update on battery below this charge (0 disables)", Value: IntValue{&minBattery}, Group: FLAG_GROUP_POLICY})
+	RegisterFlag(FlagDef{Name: "battery-wait", ArgName: "<duration>", Usage: "Waits this long for AC power on a low battery, before refusing", Value: DurationValue{&batteryWait}, Group: FLAG_GROUP_POLICY})
+	RegisterFlag(FlagDef{Name: "ignore-battery", Usage: "Updates even on a low battery", Value: BoolValue{&ignoreBatteryFlag}, Group: FLAG_GROUP_POLICY})
+	RegisterFlag(FlagDef{Name: "sysfs-root", ArgName: "<path>", Usage: "Where sysfs is mounted, for reading power supplies", Value: StringValue{&sysfsRoot}, Group: FLAG_GROUP_POLICY})
+	RegisterFlag(FlagDef{Name: "pre-hook", ArgName: "<command>", Usage: "Runs a shell command before updating, aborting on failure (repeatable)", Value: StringListFlag{&preHooks}, Group: FLAG_GROUP_POLICY})
+	RegisterFlag(FlagDef{Name: "post-hook", ArgName: "<command>", Usage: "Runs a shell command after updating, with UPDATE_FULL_RESULT set (repeatable)", Value: StringListFlag{&postHooks}, Group: FLAG_GROUP_POLICY})
+	// // Output and logging
+	RegisterFlag(FlagDef{Name: "quiet", Usage: "Only prints errors and the final run report", Value: BoolValue{&quietFlag}, Group: FLAG_GROUP_OUTPUT})
+	RegisterFlag(FlagDef{Name: "verbose", Usage: "Also prints every command run, and package manager errors", Value: BoolValue{&verboseFlag}, Group: FLAG_GROUP_OUTPUT})
+	RegisterFlag(FlagDef{Name: "no-banner", Usage: "Skips the closing statement and GitHub star request (e.g. for cron)", Value: BoolValue{&noBannerFlag}, Group: FLAG_GROUP_OUTPUT})
+	RegisterFlag(FlagDef{Name: "debug", Aliases: []string{"d"}, Usage: "Prints more verbose technical output for debugging (logs at debug level)", Value: BoolValue{&debugFlag}, Group: FLAG_GROUP_OUTPUT})
+	RegisterFlag(FlagDef{Name: "log-level", ArgName: "<level>", Usage: "Log level: debug, info, warn, error or off (default debug with -d, info with --log-file)", Value: StringValue{&logLevel}, Group: FLAG_GROUP_OUTPUT, Choices: LogLevelChoices})
+	RegisterFlag(FlagDef{Name: "log-format", ArgName: "<format>", Usage: "Log format: text or json", Value: StringValue{&logFormat}, Group: FLAG_GROUP_OUTPUT, Choices: LogFormatChoices})
+	RegisterFlag(FlagDef{Name: "log-file", ArgName: "<path>", Usage: "Writes logs to a file instead of stderr", Value: StringValue{&logFile}, Group: FLAG_GROUP_OUTPUT})
+	RegisterFlag(FlagDef{Name: "history-file", ArgName: "<path>", Usage: "Run history file (default " + DefaultHistoryPath() + ")", Value: StringValue{&historyFile}, Group: FLAG_GROUP_OUTPUT})
+	RegisterFlag(FlagDef{Name: "no-history", Usage: "Does not record this run in the history", Value: BoolValue{&noHistoryFlag}, Group: FLAG_GROUP_OUTPUT})
+	// // Configuration
+	RegisterFlag(FlagDef{Name: "config", ArgName: "<path>", Usage: "Reads options from this file (default " + CONFIG_SYSTEM_PATH + ", then the per-user file)", Value: StringValue{&configPath}, Group: FLAG_GROUP_CONFIG, NoConfig: true})
+	RegisterFlag(FlagDef{Name: "profile", ArgName: "<name>", Usage: "Applies a named profile ([profile.<name>] in the configuration)", Value: StringValue{&profileName}, Group: FLAG_GROUP_CONFIG, Choices: ProfileChoices})
+	// // Command-specific
+	RegisterFlag(FlagDef{Name: "effective", Usage: "Prints every option, after environment variables and flags", Value: BoolValue{&effectiveFlag}, Group: FLAG_GROUP_COMMAND, Commands: []string{"config"}, NoConfig: true})
+	RegisterFlag(FlagDef{Name: "limit", ArgName: "<n>", Usage: "Number of runs shown", Value: IntValue{&historyLimit}, Group: FLAG_GROUP_COMMAND, Commands: []string{"history"}, NoConfig: true})
+}
+
 // Main method
 func main() {
-	// Declare variables
-	// // Start counting time
-	timeBegin := time.Now()
-
 	// Defer exit statement
 	defer ExitStatement()
 
-	// // Get flags
-	// // // -ma / --manual-all
-	allManualShort := flag.Bool("ma", false, "Manually review and approve each update")
-	allManualLong := flag.Bool("manual-all", false, "See above")
-	// // // -ao / --alt-only
-	altOnlyShort := flag.Bool("ao", false, "Skip alternative package managers")
-	altOnlyLong := flag.Bool("alt-only", false, "See above")
-	// // // -cd / --custom-domain
-	customDomainShort := flag.String("cd", "N/A", "Test an additional domain for internet connectivity")
-	// customDomainLong := flag.String("custom-domain", "N/A", "See above")
-	// // // -oo / --disable-alt-managers
-	officialOnlyShort := flag.Bool("oo", false, "Skip updates from any unofficial package managers")
-	officialOnlyLong := flag.Bool("official-only", false, "See above")
-	// // // -yu / --yum-update
-	yumUpdateShort := flag.Bool("yu", false, "Uses legacy Yum instead of Dnf on Red-Hat Linux systems")
-	yumUpdateLong := flag.Bool("yum-update", false, "See above")
-	// // // -h / --help
-	helpShort := flag.Bool("h", false, "Prints help message")
-	helpLong := flag.Bool("help", false, "See above")
-	// // // -v / --version
-	versionShort := flag.Bool("v", false, "Print version and quit script")
-	versionLong := flag.Bool("version", false, "See above")
-	// // // -f / --flags
-	flagsShort := flag.Bool("f", false, "Print all available flags and quit script")
-	flagsLong := flag.Bool("flags", false, "Print all available flags and quit script")
-	// // // -w / --warranty
-	warrantyShort := flag.Bool("w", false, "Print warranty and quit script")
-	warrantyLong := flag.Bool("warranty", false, "See above")
-	// // // -d / --debug
-	debugShort := flag.Bool("d", false, "Print extra debugging statements")
-	debugLong := flag.Bool("debug", false, "See above")
-	// // // Parse flage
-	flag.Parse()
-	// // // Combine flags as needed
-	allManualFlag := *allManualShort || *allManualLong
-	altOnlyFlag := *altOnlyShort || *altOnlyLong
-	officialOnlyFlag := *officialOnlyShort || *officialOnlyLong
-	yumUpdateFlag := *yumUpdateShort || *yumUpdateLong
-	helpFlag := *helpShort || *helpLong
-	warrantyFlag := *warrantyShort || *warrantyLong
-	versionFlag := *versionShort || *versionLong
-	flagsFlag := *flagsShort || *flagsLong
-	customDomainFlag := *customDomainShort // TODO: Figure out combination system
-	debugFlag = *debugShort || *debugLong
+	// Remove temporary files and restore settings changed for the run, however it ends
+	AtExit(BandwidthCleanup)
+	AtExit(ProxyCleanup)
+	defer RunExitHooks()
+
+	// // Get flags and the subcommand
+	RegisterFlags()
+	command, commandArgs, err := ParseCommandLine(os.Args[1:])
+	if err != nil {
+		fmt.Println("!!", err)
+		Exit(1)
+	}
+	// // // Apply configuration files and UPDATE_FULL_* variables, below command-line flags
+	config, configErr := LoadConfig(configPath, profileName)
+	if configErr == nil {
+		configErr = config.Apply()
+	}
+	if configErr != nil {
+		fmt.Println("!!Invalid configuration:", configErr)
+		Exit(1)
+	}
+	// // // Set up output first, so every later message honours it
+	colorOutput = ColorSupported()
+	// // // Check and convert settings (config validate reports problems itself)
+	if command != "config" {
+		if problems := ValidateSettings(config); len(problems) > 0 {
+			for _, problem := range problems {
+				fmt.Println("!!" + problem.Error())
+			}
+			Exit(1)
+		}
+	}
+	activeProfile = config.Profile
+	logLevel = EffectiveLogLevel(logLevel, debugFlag, logFile)
 
 	// // // If informational flags are run (-h, -v, -f, -w), act on those first
-	if helpFlag || versionFlag || warrantyFlag || flagsFlag {
+	if helpFlag || versionFlag || warrantyFlag || flagsFlag || command == "help" || command == "version" {
 		// Run versionFlag
-		switch versionFlag {
-		case true:
+		if versionFlag || command == "version" {
 			PrintVersion()
 		}
 		// Run helpFlag
-		switch helpFlag {
-		case true:
+		if helpFlag || command == "help" {
 			PrintHelp(2)
 		}
 		// Run warrantyFlag
@@ -853,7 +1055,56 @@ func main() {
 		}
 		// Exit with error code 2
 		ExitStatement()
-		os.Exit(0)
+		Exit(0)
+	}
+
+	// // // Only the config and completion commands take arguments
+	switch command {
+	case "config":
+		Exit(RunConfigCommand(config, commandArgs))
+	case "completion":
+		Exit(CompletionCommand(config, commandArgs))
+	default:
+		if len(commandArgs) > 0 {
+			fmt.Println("!!Command [" + command + "] takes no arguments, got [" + strings.Join(commandArgs, "] [") + "]")
+			Exit(1)
+		}
+	}
+
+	// Set up logging, redacting secrets
+	if err := SetupLogging(logLevel, logFormat, logFile); err != nil {
+		fmt.Println("!!Invalid logging settings:", err)
+		Exit(1)
+	}
+	defer CloseLogging()
+	logger.Debug("starting", "version", LONG_VERSION_NUM, "os", OS_TYPE, "proxy", RedactSecrets(proxyURL), "offline", offlineFlag, "user_only", userOnlyFlag)
+
+	// Record the profile in use
+	if activeProfile != "" {
+		runReport.AddNote("Profile [" + strings.Join(config.ProfileChain, "] < [") + "] applied")
+		logger.Info("profile applied", "profile", activeProfile, "inherits", config.ProfileChain[1:])
+	}
+
+	// Run read-only subcommands
+	switch command {
+	case "check":
+		Exit(CheckCommand())
+	case "list-managers":
+		Exit(ListManagersCommand())
+	case "history":
+		Exit(HistoryCommand())
+	case "doctor":
+		Exit(DoctorCommand())
+	}
+
+	// Manual mode needs someone to answer
+	if allManualFlag && !IsTerminal(os.Stdin) {
+		fmt.Println("!!-ma / --manual-all needs an interactive terminal")
+		Exit(1)
+	}
+	if selectFlag && !IsTerminal(os.Stdin) {
+		fmt.Println("!!--select needs an interactive terminal")
+		Exit(1)
 	}
 
 	// Get user information
@@ -861,35 +1112,94 @@ func main() {
 	if err != nil {
 		fmt.Println("!!Username NOT found! :")
 		fmt.Println(err)
-		os.Exit(3) // TODO: Set up an AllError method
+		Exit(3) // TODO: Set up an AllError method
 	}
 	executingUser := currentUser.Username
 
-	// Clear screen
-	ClearScreen()
+	// Clear screen, only on terminals and unless quiet
+	if !quietFlag && IsTerminal(os.Stdout) {
+		ClearScreen()
+	}
+
+	// Handle SIGINT/SIGTERM from here on, so cancellation is reported properly
+	HandleSignals()
 
 	// Check for root permissions
-	rootUse, err = IsExecutorRoot(executingUser)
-	switch err {
-	case nil: // Do nothing, continue
-	default:
-		fmt.Println("!!User [", executingUser, "] does NOT have ROOT priviledges")
-		fmt.Println(err)
-		os.Exit(1)
+	switch userOnlyFlag {
+	case true:
+		PrintStatus("* User-only mode, skipping privilege escalation")
+	case false:
+		rootUse, err = IsExecutorRoot(executingUser)
+		switch err {
+		case nil: // Do nothing, continue
+		default:
+			PrintFailure("!!User [", executingUser, "] does NOT have ROOT priviledges")
+			PrintFailure(err)
+			// Fall back to user-level package managers, unless escalation or official managers were demanded
+			if escalateFlag != ESCALATE_AUTO || officialOnlyFlag {
+				Exit(1)
+			}
+			PrintWarning("* Only updating user-level package managers (official package managers are skipped)")
+			userOnlyFlag = true
+			rootUse = ""
+		}
+	}
+
+	// User-only runs are limited to alternative package managers
+	switch userOnlyFlag {
+	case true:
+		if officialOnlyFlag {
+			fmt.Println("!!incompatible arguments [--user-only && -oo]")
+			Exit(1)
+		}
+		altOnlyFlag = true
+		runReport.AddNote("Run was USER-ONLY: official package managers and those needing ROOT privileges skipped")
 	}
 
 	// Take initial actions based on the flags provided, including filtering, printing, etc
-	switch ActionsForFlags(altOnlyFlag, officialOnlyFlag, customDomainFlag) {
+	err = ActionsForFlags(altOnlyFlag, officialOnlyFlag, networkTargets)
+	switch err {
 	case nil: // Do nothing, continue
 	default:
-		fmt.Println(err)
-		os.Exit(1)
+		PrintFailure(err)
+		Exit(1)
 	}
 
-	// Write status on allManualFlag variable
-	switch debugFlag {
+	// Log status of allManualFlag variable
+	logger.Debug("manual mode", "manual", allManualFlag, "select", selectFlag)
+
+	// Note offline runs in the report
+	switch offlineFlag {
 	case true:
-		fmt.Println("DEBUG= allManualFlag:", allManualFlag)
+		runReport.AddNote("Run was OFFLINE: network tests skipped, package managers limited to local caches")
+	}
+
+	// Quit early if cancelled before any package manager is run
+	if IsCancelled() {
+		CancelledExit()
+	}
+
+	// Refuse to update on a low battery, after waiting for AC power if allowed
+	if err = PowerPreflight(); err != nil {
+		PrintFailure("!!", err)
+		logger.Error("power preflight failed", "min_battery", minBattery, "err", err)
+		runReport.AddStep("power check", []string{filepath.Join(sysfsRoot, POWER_SUPPLY_DIR)}, STEP_FAILED, err, 0)
+		StopSudoKeepAlive()
+		runReport.Print()
+		RecordHistory()
+		Exit(4)
+	}
+	if IsCancelled() {
+		CancelledExit()
+	}
+
+	// Run pre-update hooks, aborting the run if any fails
+	if err = RunHooks(HOOK_PRE, preHooks, nil); err != nil {
+		PrintFailure("!!", err)
+		StopSudoKeepAlive()
+		runReport.Print()
+		RecordHistory()
+		Exit(1)
 	}
 
 	// Run package manager checker/runner
@@ -897,10 +1207,36 @@ func main() {
 	switch pkgManErr {
 	case nil:
 	default:
-		fmt.Println("!!", pkgManErr)
-		os.Exit(1)
+		PrintFailure("!!", pkgManErr)
+		StopSudoKeepAlive()
+		runReport.Print()
+		RecordHistory()
+		Exit(1)
+	}
+
+	// Run post-update hooks, telling them how the run went
+	if !IsCancelled() {
+		result := "ok"
+		if runReport.Failed() {
+			result = "failed"
+		}
+		RunHooks(HOOK_POST, postHooks, []string{"UPDATE_FULL_RESULT=" + result})
+	}
+
+	// Reboot, if the policy asks for it
+	if !IsCancelled() {
+		RebootIfNeeded()
+	}
+
+	// Stop refreshing sudo credentials, if started
+	StopSudoKeepAlive()
+
+	// Print partial report and quit with exit code 130 if cancelled by USER
+	if IsCancelled() {
+		CancelledExit()
 	}
 
-	// Print finishing time
-	fmt.Println(time.Since(timeBegin))
+	// Print report, including finishing time, and record it in the history
+	runReport.Print()
+	RecordHistory()
 }
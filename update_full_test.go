@@ -0,0 +1,53 @@
+// Written by Mikhail P. Ortiz-Lunyov
+//
+// This script is licensed under the GNU Public License v3 (GPLv3)
+// Tests of package manager steps
+
+package main
+
+// Import packages
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+	"testing"
+)
+
+func TestExpectedExit(t *testing.T) {
+	tests := []struct {
+		name     string
+		pkgNum   int
+		official bool
+		step     int
+		code     int
+		want     bool
+	}{
+		{"dnf check-update, updates pending", 1, true, 0, 100, true},
+		{"yum check-update, updates pending", 4, true, 0, 100, true},
+		{"dnf check-update, error", 1, true, 0, 1, false},
+		{"dnf update exiting 100", 1, true, 1, 100, false},
+		{"swupd check-update, no update", 7, true, 0, 1, true},
+		{"swupd update, error", 7, true, 1, 1, false},
+		{"zypper patch-check, security patches pending", 3, true, 1, 101, true},
+		{"zypper patch, reboot needed", 3, true, 3, 102, true},
+		{"rpm-ostree upgrade --check, no update", 5, true, 1, 77, true},
+		{"apt update, error", 0, true, 0, 100, false},
+		{"flatpak update, error", 3, false, 0, 100, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := exec.Command("sh", "-c", "exit "+strconv.Itoa(test.code)).Run()
+			if got := ExpectedExit(test.pkgNum, test.official, test.step, err); got != test.want {
+				t.Errorf("ExpectedExit(exit %d) = %v, want %v", test.code, got, test.want)
+			}
+		})
+	}
+
+	// Only exit codes count, not other errors
+	if ExpectedExit(1, true, 0, errors.New("exit status 100")) {
+		t.Error("error without an exit code counted as expected")
+	}
+	if ExpectedExit(1, true, 0, nil) {
+		t.Error("success counted as an expected exit code")
+	}
+}